@@ -0,0 +1,109 @@
+package billdsv
+
+// DefaultBatchSize is the number of records in a batch when a Batcher has
+// neither a Size nor a byte budget.
+var DefaultBatchSize = 1000
+
+// Batcher reads records in batches, for bulk inserts into a database or
+// publishing messages in bulk. The values of a batch are copied into an arena
+// that's reused for every batch, so once the arena has grown to fit the
+// largest batch reading makes no more allocations.
+//
+// A batch and its records are only valid until the function it was passed to
+// returns, after which the arena is recycled for the next batch. Copy anything
+// that has to be kept for longer.
+type Batcher struct {
+	// Size is the greatest number of records in a batch.
+	Size int
+
+	// Bytes is the budget of the values of a batch in bytes, a record that
+	// would take a batch over it starts the next batch instead. A record
+	// larger than the budget makes a batch of its own.
+	Bytes int
+
+	arena   []byte
+	ends    []int
+	marks   []int
+	values  [][]byte
+	records []Record
+}
+
+// ReadAll reads all records from r, passing them to function in batches of
+// Size records, or fewer when they'd exceed Bytes or at the end of the
+// document. Reading stops if function returns an error, which ReadAll
+// returns.
+func (b *Batcher) ReadAll(r *Reader, function func([]Record) error) error {
+	size := b.Size
+	if size <= 0 && b.Bytes <= 0 {
+		size = DefaultBatchSize
+	}
+	b.reset()
+
+	err := r.readAll(func(row [][]byte) error {
+		if b.Bytes > 0 && len(b.marks) > 0 {
+			n := len(b.arena)
+			for _, value := range row {
+				n += len(value)
+			}
+			if n > b.Bytes {
+				if err := b.flush(r, function); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, value := range row {
+			b.arena = append(b.arena, value...)
+			b.ends = append(b.ends, len(b.arena))
+		}
+		b.marks = append(b.marks, len(b.ends))
+
+		if size > 0 && len(b.marks) >= size {
+			return b.flush(r, function)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(b.marks) > 0 {
+		return b.flush(r, function)
+	}
+	return nil
+}
+
+// flush passes the records held in the arena to function and recycles it,
+// poisoning it first if r.Poison is set.
+func (b *Batcher) flush(r *Reader, function func([]Record) error) error {
+	// the values are only sliced from the arena once the batch is complete
+	// since appending to it may have moved it. Their capacity is limited so
+	// appending to one can't overwrite the next.
+	start := 0
+	for _, end := range b.ends {
+		b.values = append(b.values, b.arena[start:end:end])
+		start = end
+	}
+
+	start = 0
+	for _, mark := range b.marks {
+		b.records = append(b.records, Record(b.values[start:mark:mark]))
+		start = mark
+	}
+
+	err := function(b.records)
+	if r.Poison {
+		poison(b.arena)
+	}
+	b.reset()
+	return err
+}
+
+// reset empties the arena, keeping its memory for the next batch.
+func (b *Batcher) reset() {
+	b.arena = b.arena[:0]
+	b.ends = b.ends[:0]
+	b.marks = b.marks[:0]
+	b.values = b.values[:0]
+	b.records = b.records[:0]
+}
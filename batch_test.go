@@ -0,0 +1,113 @@
+package billdsv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+	"github.com/pkg/errors"
+)
+
+const batchDocument = `1|Amy|first
+2|Bob
+line|second
+3|Cat|third
+4|Dan|fourth
+5|Eve|fifth
+`
+
+func readBatches(t *testing.T, b *Batcher, r *Reader) [][][]string {
+	got := [][][]string{}
+	err := b.ReadAll(r, func(batch []Record) error {
+		records := [][]string{}
+		for _, record := range batch {
+			values := []string{}
+			for _, value := range record {
+				values = append(values, string(value))
+			}
+			records = append(records, values)
+		}
+		got = append(got, records)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestBatcherSize(t *testing.T) {
+	cr := NewReader(strings.NewReader(batchDocument), 3, DefaultBufferSize)
+
+	assert.Equal(t, [][][]string{
+		{{"1", "Amy", "first"}, {"2", "Bob\nline", "second"}},
+		{{"3", "Cat", "third"}, {"4", "Dan", "fourth"}},
+		{{"5", "Eve", "fifth"}},
+	}, readBatches(t, &Batcher{Size: 2}, cr))
+}
+
+func TestBatcherBytes(t *testing.T) {
+	cr := NewReader(strings.NewReader(batchDocument), 3, DefaultBufferSize)
+	cr.Select = []string{"1"}
+
+	// a record larger than the budget makes a batch of its own
+	assert.Equal(t, [][][]string{
+		{{"Amy"}},
+		{{"Bob\nline"}},
+		{{"Cat"}, {"Dan"}},
+		{{"Eve"}},
+	}, readBatches(t, &Batcher{Bytes: 7}, cr))
+
+	cr = NewReader(strings.NewReader(batchDocument), 3, DefaultBufferSize)
+	assert.Equal(t, [][][]string{
+		{{"1", "Amy", "first"}},
+		{{"2", "Bob\nline", "second"}},
+		{{"3", "Cat", "third"}, {"4", "Dan", "fourth"}},
+		{{"5", "Eve", "fifth"}},
+	}, readBatches(t, &Batcher{Size: 2, Bytes: 20}, cr))
+}
+
+func TestBatcherAppend(t *testing.T) {
+	cr := NewReader(strings.NewReader(batchDocument), 3, DefaultBufferSize)
+
+	err := (&Batcher{Size: 5}).ReadAll(cr, func(batch []Record) error {
+		// appending to a value mustn't overwrite the next
+		batch[0][0] = append(batch[0][0], "00"...)
+		assert.Equal(t, "100", string(batch[0][0]))
+		assert.Equal(t, "Amy", string(batch[0][1]))
+		return nil
+	})
+	assert.Equal(t, nil, err)
+}
+
+func TestBatcherError(t *testing.T) {
+	cr := NewReader(strings.NewReader(batchDocument), 3, DefaultBufferSize)
+
+	batches := 0
+	err := (&Batcher{Size: 2}).ReadAll(cr, func(batch []Record) error {
+		batches++
+		return errors.New("insert failed")
+	})
+	assert.Equal(t, "insert failed", err.Error())
+	assert.Equal(t, 1, batches)
+}
+
+func TestBatcherAllocations(t *testing.T) {
+	b := &Batcher{Size: 100}
+	document := strings.Repeat("1|Amy|first\n", 1000)
+
+	// warm up the arena so it fits a batch
+	if err := b.ReadAll(NewReader(strings.NewReader(document), 3, DefaultBufferSize), func([]Record) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		cr := NewReader(strings.NewReader(document), 3, DefaultBufferSize)
+		b.ReadAll(cr, func([]Record) error { return nil })
+	})
+
+	// only the reader itself allocates
+	if allocs > 20 {
+		t.Errorf("expected reading batches not to allocate, got %v allocations", allocs)
+	}
+}
@@ -0,0 +1,134 @@
+// Command billmask writes a copy of a Bill extract with its sensitive columns
+// masked, so it can leave production.
+//
+//	billmask -heading -redact CustName -hash CustAccountNo -mask CustEmail,CustPhone < Cust.txt > safe.txt
+//
+// Hash and mask need a secret key, read from the file named by -key or
+// otherwise the BILLMASK_KEY environment variable. Keep the key the same
+// across copies to be able to join them.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	billdsv "github.com/utilitywarehouse/go-dsv-bill-reader"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "billmask:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	var (
+		fs        = flag.NewFlagSet("billmask", flag.ContinueOnError)
+		in        = fs.String("in", "", "file to read, standard input when empty")
+		out       = fs.String("out", "", "file to write, standard output when empty")
+		separator = fs.String("separator", "|", "field separator")
+		fields    = fs.Int("fields", 0, "number of fields, inferred when zero")
+		buffer    = fs.Int("buffer", 64<<10, "read buffer size in bytes, it has to fit the heading")
+		heading   = fs.Bool("heading", false, "the first line is a heading, it's copied as it is")
+		keyFile   = fs.String("key", "", "file holding the key to hash and mask with, BILLMASK_KEY when empty")
+		redaction = fs.String("redaction", billdsv.DefaultRedaction, "value redacted values are replaced with")
+		redact    = fs.String("redact", "", "comma separated columns to redact")
+		hash      = fs.String("hash", "", "comma separated columns to hash")
+		mask      = fs.String("mask", "", "comma separated columns to mask preserving their format")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(*separator) != 1 {
+		return errors.New("the separator must be a single byte")
+	}
+
+	m := &billdsv.Masker{Columns: map[string]billdsv.Masking{}, Redaction: *redaction}
+	for _, list := range []struct {
+		columns string
+		masking billdsv.Masking
+	}{{*redact, billdsv.Redact}, {*hash, billdsv.Hash}, {*mask, billdsv.Mask}} {
+		for _, column := range strings.Split(list.columns, ",") {
+			if column = strings.TrimSpace(column); column != "" {
+				m.Columns[column] = list.masking
+			}
+		}
+	}
+	if len(m.Columns) == 0 {
+		return errors.New("there are no columns to mask, see -redact, -hash and -mask")
+	}
+
+	if *keyFile != "" {
+		key, err := ioutil.ReadFile(*keyFile)
+		if err != nil {
+			return err
+		}
+		m.Key = []byte(strings.TrimSpace(string(key)))
+	} else {
+		m.Key = []byte(os.Getenv("BILLMASK_KEY"))
+	}
+
+	if *in != "" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		stdin = f
+	}
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		stdout = f
+	}
+
+	r := billdsv.NewReader(stdin, *fields, *buffer)
+	r.Separator = (*separator)[0]
+	r.SkipHeading = *heading
+
+	w := billdsv.NewWriter(stdout)
+	w.Separator = r.Separator
+
+	headed := false
+	writeHeading := func() error {
+		if headed || !*heading {
+			return nil
+		}
+		headed = true
+
+		record := [][]byte{}
+		for _, h := range r.Heading() {
+			record = append(record, []byte(h))
+		}
+		return w.Write(record)
+	}
+
+	var writeErr error
+	err := m.ReadAll(r, func(row [][]byte) {
+		if writeErr == nil {
+			writeErr = writeHeading()
+		}
+		if writeErr == nil {
+			writeErr = w.Write(row)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	if err := writeHeading(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
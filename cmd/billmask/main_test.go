@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestRun(t *testing.T) {
+	in := strings.NewReader(`Ref|Name|Notes|Email
+7860442|Mr S & Mrs A Titterington|first line
+second line|titteringtonamy@gmail.com
+`)
+	os.Setenv("BILLMASK_KEY", "secret")
+	defer os.Unsetenv("BILLMASK_KEY")
+
+	out := &bytes.Buffer{}
+	err := run([]string{"-heading", "-redact", "Name", "-mask", "Email"}, in, out)
+	assert.Equal(t, nil, err)
+
+	lines := strings.Split(out.String(), "\n")
+	assert.Equal(t, "Ref|Name|Notes|Email", lines[0])
+	assert.Equal(t, "7860442|REDACTED|first line", lines[1])
+	assert.Equal(t, true, strings.HasPrefix(lines[2], "second line|"))
+	assert.Equal(t, false, strings.Contains(lines[2], "titteringtonamy"))
+	assert.Equal(t, len("second line|titteringtonamy@gmail.com"), len(lines[2]))
+}
+
+func TestRunNoColumns(t *testing.T) {
+	err := run([]string{"-heading"}, strings.NewReader(""), &bytes.Buffer{})
+	assert.Equal(t, "there are no columns to mask, see -redact, -hash and -mask", err.Error())
+}
+
+func TestRunWideHeading(t *testing.T) {
+	// a heading as wide as the customer extract's, far larger than the
+	// library's default read buffer
+	heading, row := []string{}, []string{}
+	for i := 0; i < 134; i++ {
+		heading = append(heading, fmt.Sprintf("CustColumnName%03d", i))
+		row = append(row, fmt.Sprintf("value %d", i))
+	}
+	in := strings.NewReader(strings.Join(heading, "|") + "\n" + strings.Join(row, "|") + "\n")
+
+	out := &bytes.Buffer{}
+	err := run([]string{"-heading", "-redact", "CustColumnName001,5"}, in, out)
+	assert.Equal(t, nil, err)
+
+	lines := strings.Split(out.String(), "\n")
+	assert.Equal(t, strings.Join(heading, "|"), lines[0])
+	assert.Equal(t, true, strings.HasPrefix(lines[1], "value 0|REDACTED|value 2|value 3|value 4|REDACTED|value 6|"))
+}
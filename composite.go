@@ -0,0 +1,318 @@
+package billdsv
+
+import (
+	"bytes"
+	"reflect"
+	"regexp"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Composite describes a column that packs several values into one, such as
+// the "07510044409, 01768885022" pair of phone numbers or the
+// "JCOLEMAN/21-11-2017/13:39:16" audit stamp of a user, date and time. A
+// value is split into parts by exactly one of Separator, Widths or Pattern.
+type Composite struct {
+	// Separator splits a value into parts, each trimmed of surrounding
+	// spaces. When there's a single part the value is a list of any number of
+	// them, otherwise it may have at most as many parts as Parts, the missing
+	// ones being empty.
+	Separator string `json:"separator,omitempty" yaml:"separator,omitempty"`
+
+	// Widths are the widths in characters of parts at fixed positions, each
+	// trimmed of surrounding spaces. A value may be shorter than the widths
+	// add up to, the missing parts being empty, but not longer.
+	Widths []int `json:"widths,omitempty" yaml:"widths,omitempty"`
+
+	// Pattern is a regular expression matching the whole value whose named
+	// groups are the parts. Parts may be left out for groups that are plain
+	// strings.
+	Pattern string `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+
+	// Parts describe the parts as columns in their own right, so they're
+	// validated, read and decoded just like the columns of a record.
+	Parts []Column `json:"parts,omitempty" yaml:"parts,omitempty"`
+
+	once   sync.Once
+	err    error
+	re     *regexp.Regexp
+	groups []int
+	schema *Schema
+}
+
+// init checks the composite and sets up its parts the first time it's used.
+func (c *Composite) init() error {
+	c.once.Do(func() {
+		parts := c.Parts
+		ways := 0
+		for _, set := range []bool{c.Separator != "", c.Widths != nil, c.Pattern != ""} {
+			if set {
+				ways++
+			}
+		}
+
+		switch {
+		case ways != 1:
+			c.err = errors.New("composite needs exactly one of a separator, widths or a pattern")
+			return
+
+		case c.Separator != "" && len(parts) == 0:
+			c.err = errors.New("composite has no parts")
+			return
+
+		case c.Widths != nil && len(c.Widths) != len(parts):
+			c.err = errors.Errorf("composite has %d widths but %d parts", len(c.Widths), len(parts))
+			return
+
+		case c.Pattern != "":
+			re, err := regexp.Compile("^(?:" + c.Pattern + ")$")
+			if err != nil {
+				c.err = errors.Wrap(err, "composite pattern")
+				return
+			}
+			c.re = re
+
+			if len(parts) == 0 {
+				for _, name := range re.SubexpNames() {
+					if name != "" {
+						parts = append(parts, Column{Name: name, Type: StringType, Nullable: true})
+					}
+				}
+			}
+		groups:
+			for _, p := range parts {
+				for group, name := range re.SubexpNames() {
+					if name == p.Name {
+						c.groups = append(c.groups, group)
+						continue groups
+					}
+				}
+				c.err = errors.Errorf("composite pattern has no group named %s", p.Name)
+				return
+			}
+		}
+
+		c.schema = &Schema{Columns: parts}
+		c.schema.init()
+	})
+	return c.err
+}
+
+// part returns the column of the ith part.
+func (c *Composite) part(i int) *Column {
+	if c.list() {
+		return &c.schema.Columns[0]
+	}
+	return &c.schema.Columns[i]
+}
+
+// list reports whether values are lists of any number of a single part.
+func (c *Composite) list() bool {
+	return c.Separator != "" && len(c.Parts) == 1
+}
+
+// split appends the parts of a value to dst, they're slices of the value.
+func (c *Composite) split(dst [][]byte, value []byte) ([][]byte, error) {
+	if err := c.init(); err != nil {
+		return dst, err
+	}
+
+	if len(value) == 0 {
+		if c.list() {
+			return dst, nil
+		}
+		for range c.schema.Columns {
+			dst = append(dst, nil)
+		}
+		return dst, nil
+	}
+
+	switch {
+	case c.Separator != "":
+		n := 0
+		for {
+			end := bytes.Index(value, []byte(c.Separator))
+			if end == -1 {
+				end = len(value)
+			}
+			dst = append(dst, bytes.TrimSpace(value[:end]))
+			n++
+
+			if end == len(value) {
+				break
+			}
+			value = value[end+len(c.Separator):]
+		}
+		if !c.list() {
+			if n > len(c.Parts) {
+				return dst, errors.Errorf("value has %d parts but there should be at most %d", n, len(c.Parts))
+			}
+			for ; n < len(c.Parts); n++ {
+				dst = append(dst, nil)
+			}
+		}
+
+	case c.Widths != nil:
+		for _, width := range c.Widths {
+			end := 0
+			for i := 0; i < width && end < len(value); i++ {
+				_, size := utf8.DecodeRune(value[end:])
+				end += size
+			}
+			dst = append(dst, bytes.TrimSpace(value[:end]))
+			value = value[end:]
+		}
+		if len(value) != 0 {
+			return dst, errors.Errorf("value is longer than the %d parts at fixed positions", len(c.Widths))
+		}
+
+	default:
+		match := c.re.FindSubmatchIndex(value)
+		if match == nil {
+			return dst, errors.Errorf("value doesn't match %s", c.Pattern)
+		}
+		for _, group := range c.groups {
+			if match[2*group] == -1 {
+				dst = append(dst, nil)
+				continue
+			}
+			dst = append(dst, value[match[2*group]:match[2*group+1]])
+		}
+	}
+	return dst, nil
+}
+
+// join appends a value made up of parts to dst, the inverse of split.
+func (c *Composite) join(dst []byte, parts [][]byte) ([]byte, error) {
+	if err := c.init(); err != nil {
+		return dst, err
+	}
+	if len(parts) > len(c.Parts) && !c.list() {
+		return dst, errors.Errorf("value has %d parts but there should be at most %d", len(parts), len(c.Parts))
+	}
+
+	switch {
+	case c.Separator != "":
+		// trailing empty parts are left out the way Bill leaves them out
+		for len(parts) > 0 && len(parts[len(parts)-1]) == 0 && !c.list() {
+			parts = parts[:len(parts)-1]
+		}
+		for i, part := range parts {
+			if bytes.Contains(part, []byte(c.Separator)) {
+				return dst, errors.Errorf("part %d contains the separator %q", i, c.Separator)
+			}
+			if i > 0 {
+				dst = append(dst, c.Separator...)
+			}
+			dst = append(dst, part...)
+		}
+
+	case c.Widths != nil:
+		for i, part := range parts {
+			n := utf8.RuneCount(part)
+			if n > c.Widths[i] {
+				return dst, errors.Errorf("part %d is longer than %d characters", i, c.Widths[i])
+			}
+			dst = append(dst, part...)
+			for ; n < c.Widths[i] && i < len(parts)-1; n++ {
+				dst = append(dst, ' ')
+			}
+		}
+
+	default:
+		return dst, errors.New("can't encode a value split by a pattern")
+	}
+	return dst, nil
+}
+
+// validate checks each part of a value against its column.
+func (c *Composite) validate(value []byte) error {
+	parts, err := c.split(nil, value)
+	if err != nil {
+		return err
+	}
+	for i, part := range parts {
+		column := c.part(i)
+		if err := column.validate(part); err != nil {
+			return errors.Wrapf(err, "part %s", column.Name)
+		}
+	}
+	return nil
+}
+
+// decode sets a struct from the parts of a value, matching its fields to
+// parts by name, or a slice to the parts of a list.
+func (c *Composite) decode(dst reflect.Value, value []byte) error {
+	parts, err := c.split(nil, value)
+	if err != nil {
+		return err
+	}
+
+	if dst.Kind() == reflect.Struct {
+		return c.schema.Row(parts).Decode(dst.Addr().Interface())
+	}
+
+	s := reflect.MakeSlice(dst.Type(), len(parts), len(parts))
+	for i, part := range parts {
+		column := c.part(i)
+		if err := decodeValue(s.Index(i), part, column); err != nil {
+			return errors.Wrapf(err, "failed to decode part %s", column.Name)
+		}
+	}
+	dst.Set(s)
+	return nil
+}
+
+// encode appends a value made up of the fields of a struct or the elements of
+// a slice to dst, the inverse of decode.
+func (c *Composite) encode(dst []byte, src reflect.Value) ([]byte, error) {
+	if err := c.init(); err != nil {
+		return dst, err
+	}
+	if c.re != nil {
+		return dst, errors.New("can't encode a value split by a pattern")
+	}
+
+	var (
+		parts [][]byte
+		err   error
+	)
+	if src.Kind() == reflect.Struct {
+		if parts, err = c.schema.Encode(nil, src.Interface()); err != nil {
+			return dst, err
+		}
+	} else {
+		if src.Len() > len(c.Parts) && !c.list() {
+			return dst, errors.Errorf("value has %d parts but there should be at most %d", src.Len(), len(c.Parts))
+		}
+		parts = make([][]byte, src.Len())
+		for i := range parts {
+			column := c.part(i)
+			if parts[i], err = encodeValue(nil, src.Index(i), column); err != nil {
+				return dst, errors.Wrapf(err, "failed to encode part %s", column.Name)
+			}
+		}
+	}
+	return c.join(dst, parts)
+}
+
+// Composite returns the parts of the named composite column as a Row, so
+// they can be read by name, or decoded, like the columns of a record. The
+// parts of a list are the Row's Fields.
+func (r Row) Composite(name string) (Row, error) {
+	value, column, err := r.value(name)
+	if err != nil {
+		return Row{}, err
+	}
+	if column.Composite == nil {
+		return Row{}, errors.Errorf("column %s isn't a composite", name)
+	}
+
+	parts, err := column.Composite.split(nil, value)
+	if err != nil {
+		return Row{}, err
+	}
+	return column.Composite.schema.Row(parts), nil
+}
@@ -0,0 +1,160 @@
+package billdsv
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+const contacts = `Phones|Audit|Sort
+07510044409, 01768885022|JCOLEMAN/21-11-2017/13:39:16|AB  123
+|SMITH/04-10-2017/09:05:00|CD
+`
+
+func contactSchema() *Schema {
+	return &Schema{Columns: []Column{
+		{Name: "Phones", Type: StringType, Nullable: true, Composite: &Composite{
+			Separator: ",",
+			Parts:     []Column{{Name: "Phone", Type: StringType, MaxLength: 11}},
+		}},
+		{Name: "Audit", Type: StringType, Composite: &Composite{
+			Separator: "/",
+			Parts: []Column{
+				{Name: "User", Type: StringType},
+				{Name: "Date", Type: DateType, Layout: "02-01-2006"},
+				{Name: "Time", Type: StringType},
+			},
+		}},
+		{Name: "Sort", Type: StringType, Composite: &Composite{
+			Widths: []int{4, 3},
+			Parts: []Column{
+				{Name: "Branch", Type: StringType},
+				{Name: "Code", Type: IntType, Nullable: true},
+			},
+		}},
+	}}
+}
+
+type audit struct {
+	User string
+	Date time.Time
+	Time string
+}
+
+type sortCode struct {
+	Branch string
+	Code   *int
+}
+
+func TestCompositeDecode(t *testing.T) {
+	s := contactSchema()
+	cr := NewSchemaReader(strings.NewReader(contacts), s, DefaultBufferSize)
+	cr.SkipHeading = true
+
+	type contact struct {
+		Phones []string
+		Audit  audit
+		Sort   sortCode
+	}
+
+	got := []contact{}
+	err := cr.ReadAll(func(fields [][]byte) {
+		var v contact
+		if err := s.Row(fields).Decode(&v); err != nil {
+			t.Error(err)
+		}
+		got = append(got, v)
+
+		record, err := s.Encode(nil, v)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, string(fields[1]), string(record[1]))
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	code := 123
+	assert.Equal(t, []contact{
+		{
+			Phones: []string{"07510044409", "01768885022"},
+			Audit:  audit{"JCOLEMAN", time.Date(2017, 11, 21, 0, 0, 0, 0, time.UTC), "13:39:16"},
+			Sort:   sortCode{"AB", &code},
+		},
+		{
+			Phones: nil,
+			Audit:  audit{"SMITH", time.Date(2017, 10, 4, 0, 0, 0, 0, time.UTC), "09:05:00"},
+			Sort:   sortCode{"CD", nil},
+		},
+	}, got)
+}
+
+func TestCompositeRow(t *testing.T) {
+	s := contactSchema()
+	s.Columns[1].Composite = &Composite{
+		Pattern: `(?P<User>[A-Z]+)/(?P<Stamp>.+)`,
+		Parts: []Column{
+			{Name: "User", Type: StringType},
+			{Name: "Stamp", Type: DateTimeType, Layout: "02-01-2006/15:04:05", Location: "Europe/London"},
+		},
+	}
+	cr := NewSchemaReader(strings.NewReader(contacts), s, DefaultBufferSize)
+	cr.SkipHeading = true
+
+	got := []string{}
+	err := cr.ReadAll(func(fields [][]byte) {
+		phones, err := s.Row(fields).Composite("Phones")
+		assert.Equal(t, nil, err)
+		audit, err := s.Row(fields).Composite("Audit")
+		assert.Equal(t, nil, err)
+		user, err := audit.String("User")
+		assert.Equal(t, nil, err)
+		stamp, err := audit.Time("Stamp")
+		assert.Equal(t, nil, err)
+		got = append(got, user+" "+stamp.UTC().Format(time.RFC3339)+" "+strings.Repeat("+", len(phones.Fields)))
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, []string{"JCOLEMAN 2017-11-21T13:39:16Z ++", "SMITH 2017-10-04T08:05:00Z "}, got)
+
+	_, err = s.Encode(nil, struct{ Audit audit }{})
+	assert.Equal(t, "failed to encode column Audit: can't encode a value split by a pattern", err.Error())
+}
+
+func TestCompositeInvalidPart(t *testing.T) {
+	s := contactSchema()
+	cr := NewSchemaReader(strings.NewReader("07510044409, 017688850221|JCOLEMAN/21-11-2017/13:39:16|AB\n"), s, DefaultBufferSize)
+	err := cr.ReadAll(func([][]byte) {})
+	assert.Equal(t, "on record 1, column Phones: part Phone: value is longer than 11 characters", err.Error())
+
+	cr = NewSchemaReader(strings.NewReader("|JCOLEMAN/21-11-2017/13:39:16/X|AB\n"), s, DefaultBufferSize)
+	err = cr.ReadAll(func([][]byte) {})
+	assert.Equal(t, "on record 1, column Audit: value has 4 parts but there should be at most 3", err.Error())
+
+	cr = NewSchemaReader(strings.NewReader("|JCOLEMAN/21-11-2017/13:39:16|AB  1234\n"), s, DefaultBufferSize)
+	err = cr.ReadAll(func([][]byte) {})
+	assert.Equal(t, "on record 1, column Sort: value is longer than the 2 parts at fixed positions", err.Error())
+
+	s.Columns[2].Composite = &Composite{Separator: ",", Widths: []int{1}}
+	cr = NewSchemaReader(strings.NewReader("|JCOLEMAN/21-11-2017/13:39:16|AB\n"), s, DefaultBufferSize)
+	err = cr.ReadAll(func([][]byte) {})
+	assert.Equal(t, "on record 1, column Sort: composite needs exactly one of a separator, widths or a pattern", err.Error())
+}
+
+func TestCompositeEncodeTooManyParts(t *testing.T) {
+	s := contactSchema()
+
+	_, err := s.Encode(nil, struct{ Audit []string }{[]string{"JCOLEMAN", "21-11-2017", "13:39:16", "X"}})
+	assert.Equal(t, "failed to encode column Audit: value has 4 parts but there should be at most 3", err.Error())
+
+	_, err = s.Encode(nil, struct{ Sort []string }{[]string{"AB", "123", "X"}})
+	assert.Equal(t, "failed to encode column Sort: value has 3 parts but there should be at most 2", err.Error())
+
+	// a list has any number of parts
+	record, err := s.Encode(nil, struct{ Phones []string }{[]string{"1", "2", "3"}})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "1,2,3", string(record[0]))
+}
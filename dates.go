@@ -0,0 +1,249 @@
+package billdsv
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultDateLayouts are the layouts Bill writes dates and timestamps in, in
+// the order a DateParser without layouts of its own tries them. They're all
+// day first as in the UK, so "02/01/2006" is never read as the 1st of
+// February.
+var DefaultDateLayouts = []string{
+	"2006-01-02",
+	"02-01-2006",
+	"02/01/2006",
+	"2006/01/02",
+	"2006-01-02 15:04:05",
+	"02-01-2006/15:04:05",
+	"02-01-2006 15:04:05",
+	"02/01/2006 15:04:05",
+	"2006-01-02T15:04:05Z07:00",
+}
+
+// DateParser parses dates and timestamps that may be written in any one of
+// several layouts.
+type DateParser struct {
+	// Layouts are tried in order, DefaultDateLayouts when there are none.
+	Layouts []string
+
+	// Location is the time zone of values without an offset, UTC if nil.
+	Location *time.Location
+}
+
+// UKDateParser returns a parser for day first dates in Europe/London time,
+// which moves between GMT and BST. Local times that occur twice when the
+// clocks go back are read as BST, the first occurrence, and those skipped when
+// they go forward aren't valid.
+func UKDateParser() (DateParser, error) {
+	london, err := LoadLocation("Europe/London")
+	return DateParser{Location: london}, err
+}
+
+// Parse parses a date using the first layout it matches.
+func (p DateParser) Parse(value []byte) (time.Time, error) {
+	layouts := p.Layouts
+	if len(layouts) == 0 {
+		layouts = DefaultDateLayouts
+	}
+	return parseDate(string(value), p.Location, "", layouts)
+}
+
+// parseDate parses a date using the first of the layouts it matches.
+func parseDate(value string, loc *time.Location, first string, layouts []string) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	if first != "" {
+		t, err := time.ParseInLocation(first, value, loc)
+		if err == nil {
+			return local(t, value, first, loc)
+		}
+		if len(layouts) == 0 {
+			return t, err
+		}
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return local(t, value, layout, loc)
+		}
+	}
+
+	if first != "" {
+		layouts = append([]string{first}, layouts...)
+	}
+	return time.Time{}, errors.Errorf("%q doesn't match any of the layouts %s", value, strings.Join(layouts, ", "))
+}
+
+// local settles the local times a change of the clocks makes ambiguous, which
+// time.ParseInLocation leaves to chance. A time that occurs twice is the
+// first occurrence and one that was skipped is an error. Values with an
+// offset of their own are never ambiguous.
+func local(t time.Time, value, layout string, loc *time.Location) (time.Time, error) {
+	if loc == time.UTC || strings.Contains(layout, "07") || strings.Contains(layout, "MST") {
+		return t, nil
+	}
+
+	wall, err := time.Parse(layout, value)
+	if err != nil {
+		return t, err
+	}
+	if !sameClock(t, wall) {
+		return time.Time{}, errors.Errorf("%q doesn't exist in %s, the clocks went forward", value, loc)
+	}
+
+	// the clocks going back repeat the local times after the change, the
+	// same time at the earlier offset came first
+	_, offset := t.Zone()
+	_, before := t.Add(-12 * time.Hour).Zone()
+	if before > offset {
+		if earlier := t.Add(-time.Duration(before-offset) * time.Second); sameClock(earlier, wall) {
+			return earlier, nil
+		}
+	}
+	return t, nil
+}
+
+// sameClock reports whether t reads the same as wall, a time in UTC, on the
+// clocks of its location.
+func sameClock(t, wall time.Time) bool {
+	y, m, d := t.Date()
+	h, min, s := t.Clock()
+	return time.Date(y, m, d, h, min, s, t.Nanosecond(), time.UTC).Equal(wall)
+}
+
+var locations sync.Map
+
+// LoadLocation is time.LoadLocation with the result cached, so a column's
+// time zone is only loaded from the system's database once.
+func LoadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load time zone %q", name)
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// DefaultDateSentinels are the dates Bill writes when there's no date, they're
+// used by date and datetime columns without sentinels of their own.
+var DefaultDateSentinels = []string{"1899-12-30", "1900-01-01"}
+
+// DateCodec decodes and encodes dates that use sentinel values in place of
+// missing dates.
+type DateCodec struct {
+	// Layout is the layout dates are written in and the first tried when
+	// parsing them, DefaultDateLayout when empty.
+	Layout string
+
+	// Layouts are the further layouts tried in order when parsing. Without
+	// them or a Layout, DefaultDateLayouts are tried.
+	Layouts []string
+
+	// Location is the time zone of values without an offset, UTC if nil.
+	Location *time.Location
+
+	// Sentinels are the dates, in "2006-01-02" form, that mean there's no
+	// date whatever the time of day. The first is written for missing dates.
+	Sentinels []string
+
+	// sentinels are the Sentinels parsed once by compile
+	sentinels []civilDate
+}
+
+// civilDate is a date without a time or location.
+type civilDate struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+// compile returns the codec with its sentinels parsed, so they aren't parsed
+// again for every value.
+func (c DateCodec) compile() DateCodec {
+	c.sentinels = parseSentinels(c.Sentinels)
+	return c
+}
+
+// parseSentinels parses sentinel dates, ignoring any that aren't dates.
+func parseSentinels(sentinels []string) []civilDate {
+	dates := make([]civilDate, 0, len(sentinels))
+	for _, s := range sentinels {
+		if t, err := time.Parse("2006-01-02", s); err == nil {
+			y, m, d := t.Date()
+			dates = append(dates, civilDate{y, m, d})
+		}
+	}
+	return dates
+}
+
+// Decode parses a date, a sentinel or an empty value decodes as an invalid
+// sql.NullTime.
+func (c DateCodec) Decode(value []byte) (sql.NullTime, error) {
+	if len(value) == 0 {
+		return sql.NullTime{}, nil
+	}
+
+	layouts := c.Layouts
+	if c.Layout == "" && len(layouts) == 0 {
+		layouts = DefaultDateLayouts
+	}
+	t, err := parseDate(string(value), c.Location, c.Layout, layouts)
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+	if c.IsSentinel(t) {
+		return sql.NullTime{}, nil
+	}
+	return sql.NullTime{Time: t, Valid: true}, nil
+}
+
+// IsSentinel reports whether t falls on one of the sentinel dates.
+func (c DateCodec) IsSentinel(t time.Time) bool {
+	sentinels := c.sentinels
+	if sentinels == nil {
+		sentinels = parseSentinels(c.Sentinels)
+	}
+
+	y, m, d := t.Date()
+	for _, s := range sentinels {
+		if s.year == y && s.month == m && s.day == d {
+			return true
+		}
+	}
+	return false
+}
+
+// Append appends the formatted date to dst, writing the first sentinel when
+// t isn't valid, or nothing if there are no sentinels.
+func (c DateCodec) Append(dst []byte, t sql.NullTime) ([]byte, error) {
+	layout := c.Layout
+	if layout == "" {
+		layout = DefaultDateLayout
+	}
+
+	if t.Valid {
+		if c.Location != nil {
+			t.Time = t.Time.In(c.Location)
+		}
+		return t.Time.AppendFormat(dst, layout), nil
+	}
+	if len(c.Sentinels) == 0 {
+		return dst, nil
+	}
+
+	st, err := time.Parse("2006-01-02", c.Sentinels[0])
+	if err != nil {
+		return dst, errors.Wrapf(err, "invalid sentinel date %q", c.Sentinels[0])
+	}
+	return st.AppendFormat(dst, layout), nil
+}
@@ -0,0 +1,171 @@
+package billdsv
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestDateCodec(t *testing.T) {
+	c := DateCodec{Layout: "2006-01-02", Sentinels: DefaultDateSentinels}
+
+	for value, want := range map[string]sql.NullTime{
+		"":           {},
+		"1899-12-30": {},
+		"1900-01-01": {},
+		"2017-11-21": {Time: time.Date(2017, 11, 21, 0, 0, 0, 0, time.UTC), Valid: true},
+	} {
+		got, err := c.Decode([]byte(value))
+		assert.Equal(t, nil, err)
+		assert.Equal(t, want, got)
+	}
+
+	_, err := c.Decode([]byte("21-11-2017"))
+	assert.NotEqual(t, nil, err)
+}
+
+func TestDateCodecDateTimeSentinel(t *testing.T) {
+	c := DateCodec{Layout: "2006-01-02 15:04:05", Sentinels: DefaultDateSentinels}
+
+	got, err := c.Decode([]byte("1899-12-30 13:39:16"))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, got.Valid)
+
+	b, err := c.Append(nil, got)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "1899-12-30 00:00:00", string(b))
+}
+
+func TestDateCodecAppend(t *testing.T) {
+	c := DateCodec{Layout: "02-01-2006", Sentinels: DefaultDateSentinels}
+
+	b, err := c.Append(nil, sql.NullTime{Time: time.Date(2017, 11, 21, 0, 0, 0, 0, time.UTC), Valid: true})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "21-11-2017", string(b))
+
+	b, err = c.Append(b[:0], sql.NullTime{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "30-12-1899", string(b))
+
+	b, err = DateCodec{Layout: "2006-01-02"}.Append(b[:0], sql.NullTime{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "", string(b))
+}
+
+func TestDateCodecZero(t *testing.T) {
+	var c DateCodec
+
+	got, err := c.Decode([]byte("2017-08-28"))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, sql.NullTime{Time: time.Date(2017, 8, 28, 0, 0, 0, 0, time.UTC), Valid: true}, got)
+
+	got, err = c.Decode([]byte("28/08/2017"))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, got.Valid)
+
+	b, err := c.Append(nil, got)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "2017-08-28", string(b))
+}
+
+func TestDateParser(t *testing.T) {
+	p, err := UKDateParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	london, _ := LoadLocation("Europe/London")
+
+	for value, want := range map[string]time.Time{
+		"2017-08-28":          time.Date(2017, 8, 28, 0, 0, 0, 0, london),
+		"28-08-2017":          time.Date(2017, 8, 28, 0, 0, 0, 0, london),
+		"04/10/2017":          time.Date(2017, 10, 4, 0, 0, 0, 0, london),
+		"21-11-2017/13:39:16": time.Date(2017, 11, 21, 13, 39, 16, 0, london),
+	} {
+		got, err := p.Parse([]byte(value))
+		assert.Equal(t, nil, err)
+		assert.Equal(t, want.UTC(), got.UTC())
+	}
+
+	// British Summer Time is an hour ahead of UTC, GMT isn't
+	summer, _ := p.Parse([]byte("28-08-2017/13:39:16"))
+	assert.Equal(t, time.Date(2017, 8, 28, 12, 39, 16, 0, time.UTC), summer.UTC())
+	winter, _ := p.Parse([]byte("21-11-2017/13:39:16"))
+	assert.Equal(t, time.Date(2017, 11, 21, 13, 39, 16, 0, time.UTC), winter.UTC())
+
+	_, err = p.Parse([]byte("2015/09"))
+	assert.NotEqual(t, nil, err)
+}
+
+func TestDateParserClockChanges(t *testing.T) {
+	p, err := UKDateParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 01:30 happens twice when the clocks go back, first in BST
+	got, err := p.Parse([]byte("2017-10-29 01:30:00"))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, time.Date(2017, 10, 29, 0, 30, 0, 0, time.UTC), got.UTC())
+	name, _ := got.Zone()
+	assert.Equal(t, "BST", name)
+
+	// either side of the change is left alone
+	got, _ = p.Parse([]byte("2017-10-29 00:30:00"))
+	assert.Equal(t, time.Date(2017, 10, 28, 23, 30, 0, 0, time.UTC), got.UTC())
+	got, _ = p.Parse([]byte("2017-10-29 02:30:00"))
+	assert.Equal(t, time.Date(2017, 10, 29, 2, 30, 0, 0, time.UTC), got.UTC())
+
+	// an offset says which of the two is meant
+	got, _ = p.Parse([]byte("2017-10-29T01:30:00Z"))
+	assert.Equal(t, time.Date(2017, 10, 29, 1, 30, 0, 0, time.UTC), got.UTC())
+
+	// 01:30 never happens when the clocks go forward
+	_, err = p.Parse([]byte("2017-03-26 01:30:00"))
+	assert.Equal(t, `"2017-03-26 01:30:00" doesn't exist in Europe/London, the clocks went forward`, err.Error())
+	got, _ = p.Parse([]byte("2017-03-26 02:30:00"))
+	assert.Equal(t, time.Date(2017, 3, 26, 1, 30, 0, 0, time.UTC), got.UTC())
+}
+
+func TestSchemaColumnLayouts(t *testing.T) {
+	s := &Schema{Columns: []Column{
+		{Name: "Committed", Type: DateType, Layouts: []string{"02-01-2006"}, Location: "Europe/London"},
+		{Name: "Period", Type: PeriodType},
+	}}
+	cr := NewSchemaReader(strings.NewReader("2017-08-28|2015/09\n28-08-2017|2015/10\n"), s, DefaultBufferSize)
+
+	got := []string{}
+	err := cr.ReadAll(func(fields [][]byte) {
+		row := s.Row(fields)
+		committed, err := row.Time("Committed")
+		assert.Equal(t, nil, err)
+		period, err := row.Period("Period")
+		assert.Equal(t, nil, err)
+		got = append(got, committed.UTC().String()+" "+period.String())
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, []string{
+		"2017-08-27 23:00:00 +0000 UTC 2015/09",
+		"2017-08-27 23:00:00 +0000 UTC 2015/10",
+	}, got)
+}
+
+func TestSchemaDatesCompiledOnce(t *testing.T) {
+	s := &Schema{Columns: []Column{{Name: "Committed", Type: DateType, Location: "Europe/London"}}}
+	row := [][]byte{[]byte("2015-09-11")}
+	assert.Equal(t, nil, s.validate(1, row, nil))
+
+	// the location and sentinels aren't loaded and parsed for every value,
+	// only the value is copied to be parsed
+	allocs := testing.AllocsPerRun(100, func() {
+		s.validate(1, row, nil)
+	})
+	if allocs > 1 {
+		t.Errorf("expected a single allocation per date, got %v", allocs)
+	}
+}
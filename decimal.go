@@ -0,0 +1,325 @@
+package billdsv
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// maxScale is the most decimal places a Decimal can have, 10^18 being the
+// largest power of ten an int64 holds.
+const maxScale = 18
+
+var pow10 = func() (p [maxScale + 1]int64) {
+	p[0] = 1
+	for i := 1; i <= maxScale; i++ {
+		p[i] = p[i-1] * 10
+	}
+	return p
+}()
+
+// Decimal is a fixed-point decimal number for amounts of money, such as
+// CrCBRepaymentFee, which float64 can't represent exactly. It's an integer
+// number of units of 10^-scale, so "-150.00" is -15000 units with a scale of
+// 2. The zero Decimal is 0.
+//
+// Arithmetic is exact and panics if a result doesn't fit, beyond about ±92
+// trillion pounds when counting pence.
+type Decimal struct {
+	units int64
+	scale int
+}
+
+// NewDecimal returns units of 10^-scale, NewDecimal(-15000, 2) being -150.00.
+func NewDecimal(units int64, scale int) Decimal {
+	if scale < 0 || scale > maxScale {
+		panic(errors.Errorf("decimal scale %d isn't between 0 and %d", scale, maxScale))
+	}
+	return Decimal{units: units, scale: scale}
+}
+
+// ParseDecimal parses an amount as Bill and spreadsheets write them. A value
+// may have a leading or trailing sign, be wrapped in parentheses to make it
+// negative, start with a pound sign and group thousands with commas, so
+// "-£1,234.50", "£1,234.50-" and "(£1,234.50)" are all the same amount.
+// Surrounding spaces are ignored. The scale is the number of decimal places
+// written.
+func ParseDecimal(value []byte) (Decimal, error) {
+	return ParseDecimalImplied(value, 0)
+}
+
+// ParseDecimalImplied parses an amount like ParseDecimal, except values
+// written without a decimal point have the given number of implied decimal
+// places, so "-15000" with 2 places is -150.00. Values with a decimal point
+// are read as they're written.
+func ParseDecimalImplied(value []byte, places int) (Decimal, error) {
+	if places < 0 || places > maxScale {
+		return Decimal{}, errors.Errorf("decimal places %d isn't between 0 and %d", places, maxScale)
+	}
+
+	s := bytes.TrimSpace(value)
+	neg, signed := false, false
+	if len(s) >= 2 && s[0] == '(' && s[len(s)-1] == ')' {
+		s = bytes.TrimSpace(s[1 : len(s)-1])
+		neg, signed = true, true
+	}
+
+	// the sign may come before or after the pound sign
+	sign := func() {
+		if !signed && len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+			neg, signed = s[0] == '-', true
+			s = s[1:]
+		}
+	}
+	sign()
+	if bytes.HasPrefix(s, []byte("£")) {
+		s = s[len("£"):]
+		sign()
+	}
+	if !signed && len(s) > 0 && s[len(s)-1] == '-' {
+		neg, signed = true, true
+		s = s[:len(s)-1]
+	}
+
+	var (
+		d      = Decimal{scale: places}
+		digits int
+		group  = -1 // digits since the last comma, -1 before the first
+		point  bool
+	)
+	for i, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			if d.units > (math.MaxInt64-int64(c-'0'))/10 {
+				return Decimal{}, errors.Errorf("%q is too large for a decimal", value)
+			}
+			d.units = d.units*10 + int64(c-'0')
+			digits++
+			if point {
+				d.scale++
+				if d.scale > maxScale {
+					return Decimal{}, errors.Errorf("%q has more than %d decimal places", value, maxScale)
+				}
+			} else if group >= 0 {
+				group++
+			}
+		case c == ',' && !point && digits > 0 && (group == -1 && digits <= 3 || group == 3):
+			group = 0
+		case c == '.' && !point && (group == -1 || group == 3):
+			point, d.scale = true, 0
+			group = -1
+		default:
+			return Decimal{}, errors.Errorf("%q isn't a decimal, unexpected %q at %d", value, c, i)
+		}
+	}
+	if digits == 0 || (group != -1 && group != 3) || (point && d.scale == 0) {
+		return Decimal{}, errors.Errorf("%q isn't a decimal", value)
+	}
+
+	if neg {
+		d.units = -d.units
+	}
+	return d, nil
+}
+
+// Units returns the number of units of 10^-scale.
+func (d Decimal) Units() int64 {
+	return d.units
+}
+
+// Scale returns the number of decimal places.
+func (d Decimal) Scale() int {
+	return d.scale
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of d.
+func (d Decimal) Sign() int {
+	switch {
+	case d.units < 0:
+		return -1
+	case d.units > 0:
+		return 1
+	}
+	return 0
+}
+
+// IsZero reports whether d is zero, whatever its scale.
+func (d Decimal) IsZero() bool {
+	return d.units == 0
+}
+
+// Neg returns -d.
+func (d Decimal) Neg() Decimal {
+	if d.units == math.MinInt64 {
+		panic(errors.Errorf("decimal overflow negating %s", d))
+	}
+	return Decimal{units: -d.units, scale: d.scale}
+}
+
+// Abs returns the absolute value of d.
+func (d Decimal) Abs() Decimal {
+	if d.units < 0 {
+		return d.Neg()
+	}
+	return d
+}
+
+// Add returns d + o, with the larger of their scales.
+func (d Decimal) Add(o Decimal) Decimal {
+	d, o = align(d, o)
+	sum := d.units + o.units
+	if (sum > d.units) != (o.units > 0) {
+		panic(errors.Errorf("decimal overflow adding %s and %s", d, o))
+	}
+	return Decimal{units: sum, scale: d.scale}
+}
+
+// Sub returns d - o, with the larger of their scales.
+func (d Decimal) Sub(o Decimal) Decimal {
+	return d.Add(o.Neg())
+}
+
+// Mul returns d multiplied by an integer, such as a quantity.
+func (d Decimal) Mul(n int64) Decimal {
+	units, ok := mul(d.units, n)
+	if !ok {
+		panic(errors.Errorf("decimal overflow multiplying %s by %d", d, n))
+	}
+	return Decimal{units: units, scale: d.scale}
+}
+
+// Cmp compares d and o, returning -1 if d < o, 0 if they're equal and +1 if
+// d > o. Amounts are equal whatever their scale, 1.5 and 1.50 being equal.
+func (d Decimal) Cmp(o Decimal) int {
+	switch a, b := d.Sign(), o.Sign(); {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	case a == 0:
+		return 0
+	}
+	if d.scale != o.scale {
+		// aligning could overflow, comparing fractions can't
+		return d.Rat().Cmp(o.Rat())
+	}
+	switch {
+	case d.units < o.units:
+		return -1
+	case d.units > o.units:
+		return 1
+	}
+	return 0
+}
+
+// Rescale returns d with the given number of decimal places, rounding half
+// away from zero when it has more.
+func (d Decimal) Rescale(scale int) Decimal {
+	if scale < 0 || scale > maxScale {
+		panic(errors.Errorf("decimal scale %d isn't between 0 and %d", scale, maxScale))
+	}
+	if scale >= d.scale {
+		units, ok := mul(d.units, pow10[scale-d.scale])
+		if !ok {
+			panic(errors.Errorf("decimal overflow rescaling %s to %d places", d, scale))
+		}
+		return Decimal{units: units, scale: scale}
+	}
+
+	p := pow10[d.scale-scale]
+	units, rem := d.units/p, d.units%p
+	switch {
+	case rem >= p-rem && rem > 0:
+		units++
+	case -rem >= p+rem && rem < 0:
+		units--
+	}
+	return Decimal{units: units, scale: scale}
+}
+
+// Rat returns d as an exact fraction.
+func (d Decimal) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(big.NewInt(d.units), big.NewInt(pow10[d.scale]))
+}
+
+// Float64 returns the nearest float64 to d.
+func (d Decimal) Float64() float64 {
+	f, _ := d.Rat().Float64()
+	return f
+}
+
+// String formats d with its decimal places, "-150.00" for -15000 units with a
+// scale of 2.
+func (d Decimal) String() string {
+	return string(d.AppendFormat(nil))
+}
+
+// AppendFormat appends d formatted as String does to dst.
+func (d Decimal) AppendFormat(dst []byte) []byte {
+	units := uint64(d.units)
+	if d.units < 0 {
+		dst = append(dst, '-')
+		units = -units
+	}
+
+	start := len(dst)
+	dst = strconv.AppendUint(dst, units, 10)
+	if d.scale == 0 {
+		return dst
+	}
+
+	// pad with zeros so there's a digit before the point
+	for len(dst)-start <= d.scale {
+		dst = append(dst, 0)
+		copy(dst[start+1:], dst[start:])
+		dst[start] = '0'
+	}
+	point := len(dst) - d.scale
+	dst = append(dst, 0)
+	copy(dst[point+1:], dst[point:])
+	dst[point] = '.'
+	return dst
+}
+
+// appendImplied appends d without a decimal point at the given number of
+// implied places, the inverse of ParseDecimalImplied.
+func (d Decimal) appendImplied(dst []byte, places int) []byte {
+	return strconv.AppendInt(dst, d.Rescale(places).units, 10)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Decimal) MarshalText() ([]byte, error) {
+	return d.AppendFormat(nil), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler using ParseDecimal.
+func (d *Decimal) UnmarshalText(b []byte) (err error) {
+	*d, err = ParseDecimal(b)
+	return err
+}
+
+// align returns d and o with the same scale.
+func align(d, o Decimal) (Decimal, Decimal) {
+	switch {
+	case d.scale < o.scale:
+		d = d.Rescale(o.scale)
+	case o.scale < d.scale:
+		o = o.Rescale(d.scale)
+	}
+	return d, o
+}
+
+// mul returns a * b and whether it didn't overflow.
+func mul(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	c := a * b
+	if c/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	return c, true
+}
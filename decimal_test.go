@@ -0,0 +1,117 @@
+package billdsv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestParseDecimal(t *testing.T) {
+	for value, want := range map[string]string{
+		"-150":         "-150",
+		"4750.00":      "4750.00",
+		"+0.5":         "0.5",
+		".25":          "0.25",
+		"£1,234.50":    "1234.50",
+		"-£1,234.50":   "-1234.50",
+		"£-1,234.50":   "-1234.50",
+		"1,234.50-":    "-1234.50",
+		"(£1,234.50)":  "-1234.50",
+		" 12,345,678 ": "12345678",
+		"0.001":        "0.001",
+	} {
+		d, err := ParseDecimal([]byte(value))
+		assert.Equal(t, nil, err)
+		assert.Equal(t, want, d.String())
+	}
+
+	for _, value := range []string{
+		"", "-", "£", "1.", "1.2.3", "1,23", "1234,567", ",123", "1,234,56", "1.234,5",
+		"--1", "-1-", "(-1)", "1e5", "NaN", "99999999999999999999",
+	} {
+		_, err := ParseDecimal([]byte(value))
+		assert.NotEqual(t, nil, err, value)
+	}
+}
+
+func TestParseDecimalImplied(t *testing.T) {
+	d, err := ParseDecimalImplied([]byte("-15000"), 2)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, NewDecimal(-15000, 2), d)
+	assert.Equal(t, "-150.00", d.String())
+
+	d, err = ParseDecimalImplied([]byte("5"), 2)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "0.05", d.String())
+
+	// a decimal point overrides the implied places
+	d, err = ParseDecimalImplied([]byte("150.5"), 2)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "150.5", d.String())
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	sum := Decimal{}
+	for _, value := range []string{"0.10", "0.20", "-150", "4750.005"} {
+		d, err := ParseDecimal([]byte(value))
+		assert.Equal(t, nil, err)
+		sum = sum.Add(d)
+	}
+	assert.Equal(t, "4600.305", sum.String())
+	assert.Equal(t, "4600.31", sum.Rescale(2).String())
+	assert.Equal(t, "-4600.31", sum.Neg().Rescale(2).String())
+	assert.Equal(t, "4600.30500", sum.Rescale(5).String())
+	assert.Equal(t, "-0.10", NewDecimal(10, 2).Sub(NewDecimal(20, 2)).String())
+	assert.Equal(t, "-450.00", NewDecimal(-15000, 2).Mul(3).String())
+
+	assert.Equal(t, 0, NewDecimal(15, 1).Cmp(NewDecimal(150, 2)))
+	assert.Equal(t, -1, NewDecimal(-15, 1).Cmp(NewDecimal(1, 0)))
+	assert.Equal(t, 1, NewDecimal(151, 2).Cmp(NewDecimal(15, 1)))
+	assert.Equal(t, 0, Decimal{}.Cmp(NewDecimal(0, 2)))
+	assert.Equal(t, "3/10", NewDecimal(30, 2).Rat().String())
+	assert.Equal(t, 0.3, NewDecimal(30, 2).Float64())
+}
+
+func TestRowDecodeDecimal(t *testing.T) {
+	s := &Schema{Columns: []Column{
+		{Name: "CrCBRepaymentFee", Type: DecimalType},
+		{Name: "CrCBBalance", Type: DecimalType, ImpliedDecimals: 2, Nullable: true},
+	}}
+	cr := NewSchemaReader(strings.NewReader("-150|475000\n£1,234.5|\n"), s, DefaultBufferSize)
+
+	var v struct {
+		Fee     Decimal  `dsv:"CrCBRepaymentFee"`
+		Balance *Decimal `dsv:"CrCBBalance"`
+		Float   float64  `dsv:"CrCBRepaymentFee"`
+	}
+
+	got := [][]string{}
+	err := cr.ReadAll(func(fields [][]byte) {
+		row := s.Row(fields)
+		if err := row.Decode(&v); err != nil {
+			t.Error(err)
+		}
+		balance, err := row.Decimal("CrCBBalance")
+		assert.Equal(t, nil, err)
+		if v.Balance != nil {
+			assert.Equal(t, *v.Balance, balance)
+		}
+
+		record, err := s.Encode(nil, struct {
+			Fee     Decimal  `dsv:"CrCBRepaymentFee"`
+			Balance *Decimal `dsv:"CrCBBalance"`
+		}{v.Fee, v.Balance})
+		assert.Equal(t, nil, err)
+		got = append(got, []string{string(record[0]), string(record[1]), balance.String()})
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, [][]string{
+		{"-150", "475000", "4750.00"},
+		{"1234.5", "", "0"},
+	}, got)
+	assert.Equal(t, 1234.5, v.Float)
+}
@@ -0,0 +1,199 @@
+package billdsv
+
+import (
+	"database/sql"
+	"encoding"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	nullTimeType = reflect.TypeOf(sql.NullTime{})
+)
+
+// field maps a struct field to the column it's decoded from.
+type field struct {
+	index  int
+	column int
+}
+
+// Decode sets the fields of the struct v points to from the row. Struct fields
+// are matched to columns by their `dsv` tag or otherwise their name, fields
+// tagged "-" and unexported fields are skipped. Empty values leave fields at
+// their zero value and pointer fields nil. Dates use the column's layout and
+// sentinel dates are treated as empty, so time.Time fields are left at zero,
+// *time.Time fields nil and sql.NullTime fields invalid. Fields implementing
+// encoding.TextUnmarshaler, such as Period, decode themselves, except Decimal
+// fields which use the column's implied decimal places. Enum columns decode
+// their code, an alias being read as its code, or the whole EnumValue into
+// EnumValue fields. Bool fields use the column's TrueValues and FalseValues.
+// Struct fields are decoded from the parts of composite columns by part name,
+// and slice fields from the parts of lists.
+func (r Row) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return errors.Errorf("can't decode into %T, it must be a pointer to a struct", v)
+	}
+	rv = rv.Elem()
+
+	fields, err := r.Schema.fields(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		if f.column >= len(r.Fields) {
+			continue
+		}
+		column := &r.Schema.Columns[f.column]
+		if err := decodeValue(rv.Field(f.index), r.Fields[f.column], column); err != nil {
+			return errors.Wrapf(err, "failed to decode column %s", column.Name)
+		}
+	}
+	return nil
+}
+
+// fields returns the mapping of struct fields to columns for a struct type,
+// caching it for the next record.
+func (s *Schema) fields(t reflect.Type) ([]field, error) {
+	if fields, ok := s.decoders.Load(t); ok {
+		return fields.([]field), nil
+	}
+
+	fields := []field{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := sf.Tag.Get("dsv")
+		if name == "-" || sf.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		column := s.Index(name)
+		if column == -1 {
+			return nil, errors.Errorf("%s.%s is decoded from column %q which isn't in the schema", t.Name(), sf.Name, name)
+		}
+		fields = append(fields, field{index: i, column: column})
+	}
+
+	s.decoders.Store(t, fields)
+	return fields, nil
+}
+
+// decodeValue sets dst from a raw value.
+func decodeValue(dst reflect.Value, value []byte, column *Column) error {
+	dst.Set(reflect.Zero(dst.Type()))
+	if len(value) == 0 {
+		return nil
+	}
+
+	t := dst.Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType || t == nullTimeType {
+		dates, err := column.dates()
+		if err != nil {
+			return err
+		}
+		nt, err := dates.Decode(value)
+		if err != nil {
+			return err
+		}
+		switch {
+		case dst.Type() == nullTimeType:
+			dst.Set(reflect.ValueOf(nt))
+		case t == nullTimeType:
+			dst.Set(reflect.ValueOf(&nt))
+		case !nt.Valid:
+		case dst.Kind() == reflect.Ptr:
+			dst.Set(reflect.ValueOf(&nt.Time))
+		default:
+			dst.Set(reflect.ValueOf(nt.Time))
+		}
+		return nil
+	}
+
+	if dst.Kind() == reflect.Ptr {
+		ptr := reflect.New(dst.Type().Elem())
+		if err := decodeValue(ptr.Elem(), value, column); err != nil {
+			return err
+		}
+		dst.Set(ptr)
+		return nil
+	}
+
+	if column.Type == EnumType {
+		e, err := column.enum(value)
+		if err != nil {
+			return err
+		}
+		if p, ok := dst.Addr().Interface().(*EnumValue); ok {
+			*p = e
+			return nil
+		}
+		value = []byte(e.Code)
+	}
+	if d, ok := dst.Addr().Interface().(*Decimal); ok {
+		var err error
+		*d, err = column.decimal(value)
+		return err
+	}
+	if u, ok := dst.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText(value)
+	}
+	if column.Composite != nil && (dst.Kind() == reflect.Struct || dst.Kind() == reflect.Slice && dst.Type().Elem().Kind() != reflect.Uint8) {
+		return column.Composite.decode(dst, value)
+	}
+
+	switch dst.Kind() {
+	case reflect.String:
+		dst.SetString(string(value))
+	case reflect.Slice:
+		if dst.Type().Elem().Kind() != reflect.Uint8 {
+			return errors.Errorf("can't decode into %s", dst.Type())
+		}
+		dst.SetBytes(append([]byte(nil), value...))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(string(value), 10, dst.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dst.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(string(value), 10, dst.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dst.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		if column.Type == DecimalType {
+			d, err := column.decimal(value)
+			if err != nil {
+				return err
+			}
+			dst.SetFloat(d.Float64())
+			return nil
+		}
+		n, err := strconv.ParseFloat(string(value), dst.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dst.SetFloat(n)
+	case reflect.Bool:
+		b, err := column.bool(value)
+		if err != nil {
+			return err
+		}
+		dst.SetBool(b)
+	default:
+		return errors.Errorf("can't decode into %s", dst.Type())
+	}
+	return nil
+}
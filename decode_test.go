@@ -0,0 +1,52 @@
+package billdsv
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+type carBonus struct {
+	Number    int64     `dsv:"CrNumber"`
+	Period    string    `dsv:"CrPeriod"`
+	Repayment bool      `dsv:"CrCBRepayment"`
+	Fee       float64   `dsv:"CrCBRepaymentFee"`
+	Committed time.Time `dsv:"CrCBCommitted"`
+	Notes     *string   `dsv:"CrCBNotes"`
+	Balance   *int      `dsv:"CrCBBalance"`
+	Ignored   string    `dsv:"-"`
+}
+
+func TestRowDecode(t *testing.T) {
+	s := carBonusSchema()
+	cr := NewSchemaReader(strings.NewReader(carBonuses), s, DefaultBufferSize)
+	cr.SkipHeading = true
+
+	got := []carBonus{}
+	err := cr.ReadAll(func(fields [][]byte) {
+		b := carBonus{Ignored: "kept"}
+		if err := s.Row(fields).Decode(&b); err != nil {
+			t.Error(err)
+		}
+		got = append(got, b)
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	balances := []int{4750, 13795}
+	assert.Equal(t, []carBonus{
+		{328, "2015/09", true, -150, time.Date(2015, 9, 11, 0, 0, 0, 0, time.UTC), nil, &balances[0], "kept"},
+		{333, "2015/11", true, -150, time.Date(2015, 11, 12, 0, 0, 0, 0, time.UTC), nil, &balances[1], "kept"},
+	}, got)
+}
+
+func TestRowDecodeUnknownColumn(t *testing.T) {
+	var v struct {
+		Missing string
+	}
+	err := carBonusSchema().Row(make([][]byte, 17)).Decode(&v)
+	assert.NotEqual(t, nil, err)
+}
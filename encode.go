@@ -0,0 +1,124 @@
+package billdsv
+
+import (
+	"database/sql"
+	"encoding"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Encode appends a record for the struct v points to, the inverse of
+// Row.Decode, reusing the cells of dst. Columns without a struct field are
+// left empty. Missing dates, whether a zero time.Time, a nil *time.Time or an
+// invalid sql.NullTime, are written as the column's first sentinel so the
+// record reads back the way Bill would have written it. Fields implementing
+// encoding.TextMarshaler, such as Period, encode themselves. Decimals are
+// written without a decimal point when the column has implied decimal places.
+// Composite columns are joined from the fields of a struct or the elements of
+// a slice, except those split by a pattern which can't be.
+func (s *Schema) Encode(dst [][]byte, v interface{}) ([][]byte, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return dst, errors.Errorf("can't encode %T, it must be a struct", v)
+	}
+
+	fields, err := s.fields(rv.Type())
+	if err != nil {
+		return dst, err
+	}
+
+	for len(dst) < len(s.Columns) {
+		dst = append(dst, nil)
+	}
+	dst = dst[:len(s.Columns)]
+	for i := range dst {
+		dst[i] = dst[i][:0]
+	}
+
+	for _, f := range fields {
+		column := &s.Columns[f.column]
+		if dst[f.column], err = encodeValue(dst[f.column], rv.Field(f.index), column); err != nil {
+			return dst, errors.Wrapf(err, "failed to encode column %s", column.Name)
+		}
+	}
+	return dst, nil
+}
+
+// appendDate appends a date the way its column writes them, the column's
+// codec only being made for values that are dates.
+func appendDate(dst []byte, t sql.NullTime, column *Column) ([]byte, error) {
+	dates, err := column.dates()
+	if err != nil {
+		return dst, err
+	}
+	return dates.Append(dst, t)
+}
+
+// encodeValue appends the formatted value of src to dst.
+func encodeValue(dst []byte, src reflect.Value, column *Column) ([]byte, error) {
+	switch t := src.Interface().(type) {
+	case time.Time:
+		return appendDate(dst, sql.NullTime{Time: t, Valid: !t.IsZero()}, column)
+	case *time.Time:
+		if t == nil {
+			return appendDate(dst, sql.NullTime{}, column)
+		}
+		return appendDate(dst, sql.NullTime{Time: *t, Valid: !t.IsZero()}, column)
+	case sql.NullTime:
+		return appendDate(dst, t, column)
+	case Decimal:
+		if column.ImpliedDecimals > 0 {
+			return t.appendImplied(dst, column.ImpliedDecimals), nil
+		}
+		return t.AppendFormat(dst), nil
+	case *Decimal:
+		if t == nil {
+			return dst, nil
+		}
+		return encodeValue(dst, src.Elem(), column)
+	case EnumValue:
+		return append(dst, t.Code...), nil
+	case []byte:
+		return append(dst, t...), nil
+	case encoding.TextMarshaler:
+		if src.Kind() == reflect.Ptr && src.IsNil() {
+			return dst, nil
+		}
+		b, err := t.MarshalText()
+		return append(dst, b...), err
+	}
+
+	if src.Kind() == reflect.Ptr {
+		if src.IsNil() {
+			if column.Type == DateType || column.Type == DateTimeType {
+				return appendDate(dst, sql.NullTime{}, column)
+			}
+			return dst, nil
+		}
+		return encodeValue(dst, src.Elem(), column)
+	}
+
+	if column.Composite != nil && (src.Kind() == reflect.Struct || src.Kind() == reflect.Slice) {
+		return column.Composite.encode(dst, src)
+	}
+
+	switch src.Kind() {
+	case reflect.String:
+		return append(dst, src.String()...), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.AppendInt(dst, src.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.AppendUint(dst, src.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.AppendFloat(dst, src.Float(), 'f', -1, src.Type().Bits()), nil
+	case reflect.Bool:
+		return column.appendBool(dst, src.Bool()), nil
+	}
+	return dst, errors.Errorf("can't encode %s", src.Type())
+}
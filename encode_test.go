@@ -0,0 +1,87 @@
+package billdsv
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+type carBonusDates struct {
+	Number    int64        `dsv:"CrNumber"`
+	Committed time.Time    `dsv:"CrCBCommitted"`
+	Spare1    *time.Time   `dsv:"CrCBSpareDate1"`
+	Spare2    sql.NullTime `dsv:"CrCBSpareDate2"`
+}
+
+func TestRowDecodeSentinels(t *testing.T) {
+	s := carBonusSchema()
+	cr := NewSchemaReader(strings.NewReader(carBonuses), s, DefaultBufferSize)
+	cr.SkipHeading = true
+
+	got := []carBonusDates{}
+	err := cr.ReadAll(func(fields [][]byte) {
+		b := carBonusDates{}
+		if err := s.Row(fields).Decode(&b); err != nil {
+			t.Error(err)
+		}
+		got = append(got, b)
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, []carBonusDates{
+		{Number: 328, Committed: time.Date(2015, 9, 11, 0, 0, 0, 0, time.UTC)},
+		{Number: 333, Committed: time.Date(2015, 11, 12, 0, 0, 0, 0, time.UTC)},
+	}, got)
+}
+
+func TestSchemaEncode(t *testing.T) {
+	s := carBonusSchema()
+	spare := time.Date(2018, 7, 31, 0, 0, 0, 0, time.UTC)
+
+	buf := &bytes.Buffer{}
+	cw := NewWriter(buf)
+
+	var (
+		record [][]byte
+		err    error
+	)
+	for _, b := range []carBonusDates{
+		{Number: 328, Committed: time.Date(2015, 9, 11, 0, 0, 0, 0, time.UTC)},
+		{Number: 333, Spare1: &spare, Spare2: sql.NullTime{Time: spare, Valid: true}},
+	} {
+		if record, err = s.Encode(record, b); err != nil {
+			t.Fatal(err)
+		}
+		if err = cw.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = cw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "328||||||||2015-09-11|||||||1899-12-30|1899-12-30\n"+
+		"333||||||||1899-12-30|||||||2018-07-31|2018-07-31\n", buf.String())
+}
+
+func TestEncodeNoDates(t *testing.T) {
+	s := &Schema{Columns: []Column{{Name: "Count", Type: IntType}}}
+	v := &struct{ Count int }{7}
+	dst, err := s.Encode(nil, v)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "7", string(dst[0]))
+
+	// columns that aren't dates make no codec to encode their values
+	allocs := testing.AllocsPerRun(100, func() {
+		s.Encode(dst, v)
+	})
+	if allocs > 1 {
+		t.Errorf("expected a single allocation, got %v", allocs)
+	}
+}
@@ -0,0 +1,194 @@
+package billdsv
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+// Encoding is the character encoding of a document. Documents in encodings
+// other than UTF-8 are transcoded to UTF-8 as they're read, so the values
+// passed to ReadAll's function are UTF-8. See Reader.Delimiter for how
+// separators are matched.
+type Encoding int
+
+const (
+	// UTF8 documents, as well as plain ASCII, are read as they are.
+	UTF8 Encoding = iota
+	// Windows1252 is the Windows code page Bill exports are usually written
+	// in, a superset of ISO-8859-1 with printable characters such as "€" in
+	// place of most of the C1 control codes.
+	Windows1252
+	// ISO88591 is Latin-1, every byte maps directly to the same code point.
+	ISO88591
+	// UTF16 detects the byte order from the byte order mark, assuming little
+	// endian when there isn't one.
+	UTF16
+	// UTF16LE is little endian UTF-16, a byte order mark is discarded.
+	UTF16LE
+	// UTF16BE is big endian UTF-16, a byte order mark is discarded.
+	UTF16BE
+)
+
+// decodeByte decodes a byte of a single byte encoding, reporting false if the
+// encoding isn't one and the byte isn't ASCII.
+func (e Encoding) decodeByte(c byte) (rune, bool) {
+	switch {
+	case c < utf8.RuneSelf:
+		return rune(c), true
+	case e == Windows1252 && c < 0xa0:
+		return windows1252[c-0x80], true
+	case e == Windows1252 || e == ISO88591:
+		return rune(c), true
+	}
+	return 0, false
+}
+
+// windows1252 maps bytes 0x80 to 0x9F to code points, the five bytes that are
+// undefined map to the C1 control code of the same value.
+var windows1252 = [32]rune{
+	'€', '\u0081', '‚', 'ƒ', '„', '…', '†', '‡',
+	'ˆ', '‰', 'Š', '‹', 'Œ', '\u008d', 'Ž', '\u008f',
+	'\u0090', '‘', '’', '“', '”', '•', '–', '—',
+	'˜', '™', 'š', '›', 'œ', '\u009d', 'ž', 'Ÿ',
+}
+
+// decoder transcodes a document to UTF-8. Source bytes that don't make up a
+// whole character are held back until the next read, so a character is never
+// split between the chunks handed to the parser.
+type decoder struct {
+	r      io.Reader
+	enc    Encoding
+	bom    bool
+	eof    bool
+	src    []byte
+	srcLen int
+	dst    []byte
+	out    []byte
+}
+
+func newDecoder(r io.Reader, enc Encoding) *decoder {
+	return &decoder{
+		r:   r,
+		enc: enc,
+		src: make([]byte, 4096),
+		// each source byte becomes at most 3 bytes of UTF-8, a UTF-16 code
+		// unit at most 3 and a surrogate pair 4
+		dst: make([]byte, 0, 3*4096+utf8.UTFMax),
+	}
+}
+
+func (d *decoder) Read(p []byte) (int, error) {
+	for len(d.out) == 0 {
+		if d.eof {
+			return 0, io.EOF
+		}
+		if err := d.decode(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
+
+// decode reads another chunk from the source and transcodes every complete
+// character in it.
+func (d *decoder) decode() error {
+	n, err := d.r.Read(d.src[d.srcLen:])
+	d.srcLen += n
+	if err == io.EOF {
+		d.eof = true
+	} else if err != nil {
+		return err
+	}
+
+	src := d.src[:d.srcLen]
+	if d.enc >= UTF16 && !d.bom {
+		if len(src) < 2 && !d.eof {
+			return nil
+		}
+		d.bom = true
+		if len(src) >= 2 {
+			switch {
+			case src[0] == 0xff && src[1] == 0xfe && d.enc != UTF16BE:
+				d.enc, src = UTF16LE, src[2:]
+			case src[0] == 0xfe && src[1] == 0xff && d.enc != UTF16LE:
+				d.enc, src = UTF16BE, src[2:]
+			}
+		}
+		if d.enc == UTF16 {
+			d.enc = UTF16LE
+		}
+	}
+
+	dst := d.dst[:0]
+	switch d.enc {
+	case Windows1252, ISO88591:
+		for _, c := range src {
+			if c < utf8.RuneSelf {
+				dst = append(dst, c)
+			} else {
+				r, _ := d.enc.decodeByte(c)
+				dst = appendRune(dst, r)
+			}
+		}
+		src = src[len(src):]
+
+	case UTF16LE, UTF16BE:
+		for len(src) >= 2 {
+			u := d.unit(src)
+			if u < 0xd800 || u >= 0xe000 {
+				dst = appendRune(dst, rune(u))
+				src = src[2:]
+				continue
+			}
+
+			if u >= 0xdc00 {
+				// a low surrogate without a high surrogate before it
+				dst = appendRune(dst, utf8.RuneError)
+				src = src[2:]
+				continue
+			}
+
+			if len(src) < 4 {
+				if !d.eof {
+					// the low surrogate hasn't been read yet
+					break
+				}
+				dst = appendRune(dst, utf8.RuneError)
+				src = src[2:]
+				continue
+			}
+
+			if l := d.unit(src[2:]); l >= 0xdc00 && l < 0xe000 {
+				dst = appendRune(dst, 0x10000+(rune(u)-0xd800)<<10+(rune(l)-0xdc00))
+				src = src[4:]
+			} else {
+				dst = appendRune(dst, utf8.RuneError)
+				src = src[2:]
+			}
+		}
+		if d.eof && len(src) == 1 {
+			dst = appendRune(dst, utf8.RuneError)
+			src = src[1:]
+		}
+	}
+
+	// hold on to an incomplete character for the next read
+	d.srcLen = copy(d.src, src)
+	d.out = dst
+	return nil
+}
+
+func (d *decoder) unit(b []byte) uint16 {
+	if d.enc == UTF16BE {
+		return uint16(b[0])<<8 | uint16(b[1])
+	}
+	return uint16(b[1])<<8 | uint16(b[0])
+}
+
+func appendRune(dst []byte, r rune) []byte {
+	var b [utf8.UTFMax]byte
+	return append(dst, b[:utf8.EncodeRune(b[:], r)]...)
+}
@@ -0,0 +1,94 @@
+package billdsv
+
+import (
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf16"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestReaderWindows1252(t *testing.T) {
+	f := strings.NewReader("1000|caf\xe9 \x80 \x93quoted\x94|final\n1001|second\n\xa3 string|final\n")
+
+	want := [][]string{
+		{"1000", "café € “quoted”", "final"},
+		{"1001", "second\n£ string", "final"},
+	}
+
+	got := [][]string{}
+
+	cr := NewReader(iotest.OneByteReader(f), 3, DefaultBufferSize)
+	cr.Encoding = Windows1252
+
+	err := cr.ReadAll(func(row [][]byte) {
+		rowStrings := make([]string, 3)
+		for i, c := range row {
+			rowStrings[i] = string(c)
+		}
+		got = append(got, rowStrings)
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, want, got)
+}
+
+func TestReaderUTF16(t *testing.T) {
+	want := [][]string{
+		{"1000", "first | string 😀", "final"},
+		{"1001", "second\nstring", "final"},
+	}
+
+	for _, enc := range []Encoding{UTF16, UTF16LE, UTF16BE} {
+		doc := utf16.Encode([]rune("\ufeff1000¦first | string 😀¦final\r\n1001¦second\nstring¦final\r\n"))
+		b := []byte{}
+		for _, u := range doc {
+			if enc == UTF16BE {
+				b = append(b, byte(u>>8), byte(u))
+			} else {
+				b = append(b, byte(u), byte(u>>8))
+			}
+		}
+
+		got := [][]string{}
+
+		cr := NewReader(iotest.OneByteReader(strings.NewReader(string(b))), 3, 16)
+		cr.Delimiter = "¦"
+		cr.Encoding = enc
+
+		err := cr.ReadAll(func(row [][]byte) {
+			rowStrings := make([]string, 3)
+			for i, c := range row {
+				rowStrings[i] = string(c)
+			}
+			got = append(got, rowStrings)
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		assert.Equal(t, want, got)
+	}
+}
+
+func TestReaderLatin1Separator(t *testing.T) {
+	cr := NewReader(strings.NewReader("caf\xe9\xa6b\n\xa3\xa6d\n"), 2, DefaultBufferSize)
+	cr.Encoding = ISO88591
+	cr.Separator = 0xa6
+
+	assert.Equal(t, [][]string{{"café", "b"}, {"£", "d"}}, readStrings(t, cr))
+}
+
+func TestReaderEncodingNonASCII(t *testing.T) {
+	cr := NewReader(strings.NewReader("a\xa6b\n"), 2, DefaultBufferSize)
+	cr.Encoding = UTF16
+	cr.Separator = 0xa6
+	assert.Equal(t, "separator 0xa6 isn't a character in the document's encoding, set Delimiter instead", cr.ReadAll(func([][]byte) {}).Error())
+
+	cr = NewReader(strings.NewReader("\xabb\xab|c\n"), 2, DefaultBufferSize)
+	cr.Encoding = ISO88591
+	cr.Quote = 0xab
+	assert.Equal(t, "the quote and escape must be ASCII in documents with an encoding", cr.ReadAll(func([][]byte) {}).Error())
+}
@@ -0,0 +1,99 @@
+package billdsv
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Enum is a named set of codes shared by the enum columns of a schema, such
+// as the "C" and "E" codes Bill uses for a customer's status.
+type Enum struct {
+	Name   string      `json:"name" yaml:"name"`
+	Values []EnumValue `json:"values" yaml:"values"`
+}
+
+// EnumValue is a code an enum column may hold along with its label.
+type EnumValue struct {
+	Code  string `json:"code" yaml:"code"`
+	Label string `json:"label,omitempty" yaml:"label,omitempty"`
+
+	// Aliases are other ways Bill writes the code, such as "YES" for "Yes",
+	// they're read as the code.
+	Aliases []string `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+}
+
+func (v EnumValue) String() string {
+	if v.Label != "" {
+		return v.Label
+	}
+	return v.Code
+}
+
+// enumCodes looks up the values of an enum column by code or alias.
+type enumCodes struct {
+	values []EnumValue
+	codes  map[string]int
+}
+
+func newEnumCodes(values []EnumValue) *enumCodes {
+	e := &enumCodes{values: values, codes: make(map[string]int, len(values))}
+	for i, v := range values {
+		e.codes[v.Code] = i
+		for _, alias := range v.Aliases {
+			if _, ok := e.codes[alias]; !ok {
+				e.codes[alias] = i
+			}
+		}
+	}
+	return e
+}
+
+// resolveEnums sets up the codes of every enum column from its Values or the
+// named Enum.
+func (s *Schema) resolveEnums() {
+	enums := make(map[string][]EnumValue, len(s.Enums))
+	for _, e := range s.Enums {
+		enums[e.Name] = e.Values
+	}
+
+	for i := range s.Columns {
+		c := &s.Columns[i]
+		if c.Type != EnumType {
+			continue
+		}
+
+		if c.Enum != "" {
+			if values, ok := enums[c.Enum]; ok {
+				c.codes = newEnumCodes(values)
+			}
+			continue
+		}
+		values := make([]EnumValue, len(c.Values))
+		for i, v := range c.Values {
+			values[i].Code = v
+		}
+		c.codes = newEnumCodes(values)
+	}
+}
+
+// enum returns the value of an enum column a raw value is the code or an
+// alias of.
+func (c *Column) enum(value []byte) (EnumValue, error) {
+	if c.codes == nil {
+		if c.Enum != "" {
+			return EnumValue{}, errors.Errorf("enum %q isn't defined", c.Enum)
+		}
+		return EnumValue{}, errors.New("column isn't part of a schema")
+	}
+
+	if i, ok := c.codes.codes[string(value)]; ok {
+		return c.codes.values[i], nil
+	}
+
+	codes := make([]string, len(c.codes.values))
+	for i, v := range c.codes.values {
+		codes[i] = v.Code
+	}
+	return EnumValue{}, errors.Errorf("value isn't one of %s", strings.Join(codes, ", "))
+}
@@ -0,0 +1,109 @@
+package billdsv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+const flagSchema = `
+enums:
+  - name: status
+    values:
+      - code: C
+        label: Current
+      - code: E
+        label: Ended
+        aliases: [X]
+columns:
+  - name: Status
+    type: enum
+    enum: status
+  - name: Eligible
+    type: enum
+    values: ["Yes", "No"]
+  - name: Committed
+    type: bool
+    trueValues: ["C"]
+    falseValues: ["E"]
+  - name: Repayment
+    type: bool
+`
+
+type status string
+
+type flags struct {
+	Status    status    `dsv:"Status"`
+	Label     EnumValue `dsv:"Status"`
+	Eligible  string
+	Committed bool
+	Repayment bool
+}
+
+func TestEnumsAndBools(t *testing.T) {
+	s, err := ReadSchema(strings.NewReader(flagSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cr := NewSchemaReader(strings.NewReader("C|Yes|c|YES\nX|No|E|0\nE|No|e|n\n"), s, DefaultBufferSize)
+
+	got := []flags{}
+	err = cr.ReadAll(func(fields [][]byte) {
+		var v flags
+		if err := s.Row(fields).Decode(&v); err != nil {
+			t.Error(err)
+		}
+		got = append(got, v)
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	current := EnumValue{Code: "C", Label: "Current"}
+	ended := EnumValue{Code: "E", Label: "Ended", Aliases: []string{"X"}}
+	assert.Equal(t, []flags{
+		{"C", current, "Yes", true, true},
+		{"E", ended, "No", false, false},
+		{"E", ended, "No", false, false},
+	}, got)
+	assert.Equal(t, "Ended", got[1].Label.String())
+
+	record, err := s.Encode(nil, struct {
+		Status    EnumValue
+		Eligible  string
+		Committed bool
+		Repayment bool
+	}{ended, "No", false, false})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, [][]byte{[]byte("E"), []byte("No"), []byte("E"), []byte("No")}, record)
+}
+
+func TestUnknownEnumCode(t *testing.T) {
+	s, err := ReadSchema(strings.NewReader(flagSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cr := NewSchemaReader(strings.NewReader("C|Yes|C|Y\nC|Yes|C|Y\nL|Yes|C|Y\n"), s, DefaultBufferSize)
+	err = cr.ReadAll(func([][]byte) {})
+
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	assert.Equal(t, 3, verr.Record)
+	assert.Equal(t, "Status", verr.Column)
+	assert.Equal(t, "L", verr.Value)
+	assert.Equal(t, "on record 3, column Status: value isn't one of C, E", err.Error())
+
+	cr = NewSchemaReader(strings.NewReader("C|Yes|Y|Y\n"), s, DefaultBufferSize)
+	err = cr.ReadAll(func([][]byte) {})
+	assert.Equal(t, "on record 1, column Committed: value isn't one of C, E", err.Error())
+}
+
+func TestReadSchemaUndefinedEnum(t *testing.T) {
+	_, err := ReadSchema(strings.NewReader("columns:\n  - name: Status\n    type: enum\n    enum: status\n"))
+	assert.Equal(t, `column Status uses enum "status" which isn't defined`, err.Error())
+}
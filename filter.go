@@ -0,0 +1,103 @@
+package billdsv
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Filter keeps the records whose value of a column matches, see
+// Reader.Filters. Values are compared as they were read, before any text
+// policy or transform applies.
+type Filter struct {
+	// Column names the column by the schema or heading, or by its index when
+	// no column has the name.
+	Column string
+
+	// Values keeps the records whose value is one of them.
+	Values []string
+
+	// Match keeps the records for which it returns true, it's only given the
+	// value until it returns. It's checked after Values when both are set.
+	Match func(value []byte) bool
+}
+
+// FilterStats counts the records a filter checked and those it dropped.
+type FilterStats struct {
+	Column   string
+	Checked  int
+	Filtered int
+}
+
+// filter is a Filter resolved to the index of its column.
+type filter struct {
+	column int
+	values map[string]struct{}
+	match  func(value []byte) bool
+	stats  FilterStats
+}
+
+// compileFilters resolves the reader's filters, once the field count and
+// heading are known.
+func (r *Reader) compileFilters() error {
+	if len(r.Filters) == 0 {
+		return nil
+	}
+
+	r.filters = make([]filter, len(r.Filters))
+	r.filtering = make([]bool, r.fields)
+	for i, f := range r.Filters {
+		if f.Values == nil && f.Match == nil {
+			return errors.Errorf("filter on column %s has neither values nor a match function", f.Column)
+		}
+
+		column, err := r.column(f.Column, "filter")
+		if err != nil {
+			return err
+		}
+
+		c := filter{column: column, match: f.Match, stats: FilterStats{Column: f.Column}}
+		if f.Values != nil {
+			c.values = make(map[string]struct{}, len(f.Values))
+			for _, v := range f.Values {
+				c.values[v] = struct{}{}
+			}
+		}
+		r.filters[i] = c
+		r.filtering[column] = true
+	}
+	return nil
+}
+
+// filter checks the value of the current field against the filters on its
+// column, reporting whether the record is kept.
+func (r *Reader) filter(value []byte) bool {
+	for i := range r.filters {
+		f := &r.filters[i]
+		if f.column != r.field {
+			continue
+		}
+
+		f.stats.Checked++
+		if f.values != nil {
+			if _, ok := f.values[string(value)]; !ok {
+				f.stats.Filtered++
+				return false
+			}
+		}
+		if f.match != nil && !f.match(value) {
+			f.stats.Filtered++
+			return false
+		}
+	}
+	return true
+}
+
+// FilterStats returns the counts of each of the reader's Filters, in order,
+// once reading has started. Filters are checked in the order of their columns
+// so those on later columns aren't checked for records already dropped.
+func (r *Reader) FilterStats() []FilterStats {
+	stats := make([]FilterStats, len(r.filters))
+	for i := range r.filters {
+		stats[i] = r.filters[i].stats
+	}
+	return stats
+}
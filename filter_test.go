@@ -0,0 +1,68 @@
+package billdsv
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestReaderFilters(t *testing.T) {
+	cr := NewReader(strings.NewReader(carBonuses+"340|2016/01|1155250|006310|No|-150.50|No|0|2016-01-04|Repaid\nin full|Scheme2||0|0|0|1899-12-30|1899-12-30\n"), 0, DefaultBufferSize)
+	cr.SkipHeading = true
+	cr.Filters = []Filter{
+		{Column: "CrCBBonusEligibl", Values: []string{"No"}},
+		{Column: "CrCBBalance", Match: func(value []byte) bool { return !bytes.Equal(value, []byte("4750")) }},
+	}
+
+	assert.Equal(t, [][]string{
+		{"333", "2015/11", "1155246", "006308", "Yes", "-150", "No", "0", "2015-11-12", "", "Scheme3", "", "13795", "0", "0", "1899-12-30", "1899-12-30"},
+		{"340", "2016/01", "1155250", "006310", "No", "-150.50", "No", "0", "2016-01-04", "Repaid\nin full", "Scheme2", "", "0", "0", "0", "1899-12-30", "1899-12-30"},
+	}, readStrings(t, cr))
+
+	assert.Equal(t, []FilterStats{
+		{Column: "CrCBBonusEligibl", Checked: 3, Filtered: 1},
+		{Column: "CrCBBalance", Checked: 2, Filtered: 0},
+	}, cr.FilterStats())
+	assert.Equal(t, 3, cr.Diagnostics().Records)
+	assert.Equal(t, 1, cr.Diagnostics().Filtered)
+}
+
+func TestReaderFiltersSelect(t *testing.T) {
+	cr := NewReader(strings.NewReader(`1|"quoted|value"|Amy|x
+2|"multi
+line"|Bob|y
+3|"multi
+line"|Cat|x
+`), 4, DefaultBufferSize)
+	cr.Quote = '"'
+	cr.Select = []string{"2"}
+	cr.Filters = []Filter{{Column: "3", Values: []string{"x"}}}
+
+	assert.Equal(t, [][]string{{"Amy"}, {"Cat"}}, readStrings(t, cr))
+	assert.Equal(t, []FilterStats{{Column: "3", Checked: 3, Filtered: 1}}, cr.FilterStats())
+}
+
+func TestReaderFiltersSkipCopying(t *testing.T) {
+	cr := NewReader(strings.NewReader("1|keep|a\n2|drop|b\n"), 3, DefaultBufferSize)
+	cr.Filters = []Filter{{Column: "0", Values: []string{"1"}}}
+
+	got := readStrings(t, cr)
+	assert.Equal(t, [][]string{{"1", "keep", "a"}}, got)
+
+	// the dropped record's values after the filtered column were never copied
+	assert.Equal(t, "keep", string(cr.rowBuffer[1]))
+}
+
+func TestReaderFiltersErrors(t *testing.T) {
+	cr := NewReader(strings.NewReader("Id|Name\n1|Amy\n"), 0, DefaultBufferSize)
+	cr.SkipHeading = true
+	cr.Filters = []Filter{{Column: "Email", Values: []string{"x"}}}
+	assert.Equal(t, `no column named "Email" to filter`, cr.ReadAll(func([][]byte) {}).Error())
+
+	cr = NewReader(strings.NewReader("Id|Name\n1|Amy\n"), 0, DefaultBufferSize)
+	cr.SkipHeading = true
+	cr.Filters = []Filter{{Column: "Name"}}
+	assert.Equal(t, "filter on column Name has neither values nor a match function", cr.ReadAll(func([][]byte) {}).Error())
+}
@@ -0,0 +1,347 @@
+package billdsv
+
+import (
+	"database/sql"
+	"io"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// words are the words synthetic text is made of, taken from Bill's notes.
+var words = strings.Fields(`customer has requested to book later cancelled their
+appointment install date installation time status booked email sent general
+system bulbs account payment direct debit meter reading tariff gas electricity
+broadband mobile landline bill credit refund callback engineer visit confirmed
+address moved home welcome pack letter`)
+
+// Generator writes synthetic documents for load tests and fixtures that can be
+// shared, since they hold nothing from a real extract. Values match the types
+// of the schema's columns, and when generating from a profile its null rates,
+// ranges and lengths too. A profile's most frequent values are only repeated
+// for enum and bool columns, or when they appear more than once, so values
+// unique to a customer never are.
+type Generator struct {
+	// Profile is the profile of a real document to mimic, it takes the place
+	// of Schema when set.
+	Profile *Profile
+
+	// Schema describes the columns to generate when there's no Profile.
+	Schema *Schema
+
+	// Records is the number of records to generate.
+	Records int
+
+	// Heading adds a heading of the column names.
+	Heading bool
+
+	// Seed seeds the random values, the same seed generating the same
+	// document.
+	Seed int64
+
+	// NullRate is the rate of empty values in nullable columns, unless the
+	// profile has their rate.
+	NullRate float64
+
+	// SentinelRate is the rate of dates written as sentinels. A profile's
+	// sentinels are generated at their own rate, as one of the most frequent
+	// values of their column.
+	SentinelRate float64
+
+	// MultiLineRate is the rate of values of multi-line text columns with a
+	// line break, except in the last column where Reader can't read them.
+	MultiLineRate float64
+
+	// GarbageRate is the rate of values of text columns replaced by garbage
+	// bytes, as found in corrupted Bill notes.
+	GarbageRate float64
+}
+
+// columnGenerator generates the values of a column.
+type columnGenerator struct {
+	column       *Column
+	last         bool
+	nullRate     float64
+	sentinelRate float64
+
+	// the most frequent values of the profile and the share of non-empty
+	// values they make up
+	top      []ValueCount
+	topTotal int
+	topRate  float64
+
+	// the range of the profile's numbers, exact for integers
+	min, max       float64
+	minInt, maxInt int64
+	ranged         bool
+	minLen, maxLen int
+}
+
+// Generate writes the document to w and flushes it.
+func (g *Generator) Generate(w *Writer) error {
+	rnd := rand.New(rand.NewSource(g.Seed))
+	columns := g.columns()
+
+	if g.Heading {
+		heading := make([][]byte, len(columns))
+		for i, c := range columns {
+			heading[i] = []byte(c.column.Name)
+		}
+		if err := w.Write(heading); err != nil {
+			return err
+		}
+	}
+
+	// garbage mustn't split the record
+	avoid := w.Delimiter + w.Terminator + string([]byte{w.Separator}) + "\r\n"
+
+	record := make([][]byte, len(columns))
+	for n := 0; n < g.Records; n++ {
+		for i, c := range columns {
+			record[i] = g.value(record[i][:0], rnd, c, avoid)
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// Reader returns a reader of the generated document with pipe separated
+// values, generated as it's read. It must be closed if it isn't read to the
+// end, or generating the rest of the document waits on it forever.
+func (g *Generator) Reader() io.ReadCloser {
+	r, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(g.Generate(NewWriter(pw)))
+	}()
+	return r
+}
+
+// columns sets up the generators of the columns.
+func (g *Generator) columns() []*columnGenerator {
+	var columns []*columnGenerator
+	if g.Profile != nil {
+		for i := range g.Profile.Columns {
+			p := &g.Profile.Columns[i]
+			c := &columnGenerator{
+				column: &p.Column,
+				minLen: p.MinLength,
+				maxLen: p.MaxLength,
+			}
+			if g.Profile.Records > 0 {
+				c.nullRate = float64(p.Nulls) / float64(g.Profile.Records)
+			}
+			lowCardinality := p.Type == EnumType || p.Type == BoolType
+			for _, v := range p.Top {
+				if lowCardinality || v.Count > 1 {
+					c.top = append(c.top, v)
+					c.topTotal += v.Count
+				}
+			}
+			if values := g.Profile.Records - p.Nulls; values > 0 {
+				c.topRate = float64(c.topTotal) / float64(values)
+			}
+			if p.Min != "" && p.Max != "" {
+				c.ranged = c.bounds(p.Type, p.Min, p.Max) == nil
+			}
+			columns = append(columns, c)
+		}
+	} else if g.Schema != nil {
+		g.Schema.init()
+		for i := range g.Schema.Columns {
+			column := &g.Schema.Columns[i]
+			c := &columnGenerator{column: column, maxLen: column.MaxLength, sentinelRate: g.SentinelRate}
+			if column.Nullable {
+				c.nullRate = g.NullRate
+			}
+			columns = append(columns, c)
+		}
+	}
+
+	for _, c := range columns {
+		if c.maxLen == 0 {
+			c.maxLen = 20
+		}
+		if c.minLen == 0 || c.minLen > c.maxLen {
+			c.minLen = 1
+		}
+	}
+	if len(columns) > 0 {
+		columns[len(columns)-1].last = true
+	}
+	return columns
+}
+
+// bounds sets the range of a column's numbers, in whichever order they were
+// given.
+func (c *columnGenerator) bounds(typ Type, min, max string) (err error) {
+	if typ == IntType {
+		if c.minInt, err = strconv.ParseInt(min, 10, 64); err != nil {
+			return err
+		}
+		if c.maxInt, err = strconv.ParseInt(max, 10, 64); err != nil {
+			return err
+		}
+		if c.minInt > c.maxInt {
+			c.minInt, c.maxInt = c.maxInt, c.minInt
+		}
+		return nil
+	}
+
+	if c.min, err = strconv.ParseFloat(min, 64); err != nil {
+		return err
+	}
+	if c.max, err = strconv.ParseFloat(max, 64); err != nil {
+		return err
+	}
+	if c.min > c.max {
+		c.min, c.max = c.max, c.min
+	}
+	return nil
+}
+
+// randInt returns a random integer between min and max inclusive.
+func randInt(rnd *rand.Rand, min, max int64) int64 {
+	if span := uint64(max) - uint64(min); span < math.MaxInt64 {
+		return min + rnd.Int63n(int64(span)+1)
+	}
+
+	// the range is too wide for Int63n but covers at least half of all
+	// integers, so drawing from all of them soon lands in it
+	for {
+		if n := int64(rnd.Uint64()); n >= min && n <= max {
+			return n
+		}
+	}
+}
+
+// value appends a value of a column to dst.
+func (g *Generator) value(dst []byte, rnd *rand.Rand, c *columnGenerator, avoid string) []byte {
+	if rnd.Float64() < c.nullRate {
+		return dst
+	}
+	if len(c.top) > 0 && rnd.Float64() < c.topRate {
+		n := rnd.Intn(c.topTotal)
+		for _, v := range c.top {
+			if n -= v.Count; n < 0 {
+				return append(dst, v.Value...)
+			}
+		}
+	}
+
+	column := c.column
+	if column.Composite != nil && column.Composite.Pattern == "" && column.Composite.init() == nil {
+		composite := column.Composite
+		parts := make([][]byte, len(composite.schema.Columns))
+		for i := range parts {
+			parts[i] = g.value(nil, rnd, &columnGenerator{column: composite.part(i), minLen: 1, maxLen: 8}, avoid+composite.Separator)
+		}
+		if composite.Widths != nil {
+			for i, part := range parts {
+				if len(part) > composite.Widths[i] {
+					parts[i] = part[:composite.Widths[i]]
+				}
+			}
+		}
+		if joined, err := composite.join(dst, parts); err == nil {
+			return joined
+		}
+		return dst
+	}
+
+	switch column.Type {
+	case IntType:
+		min, max := int64(0), int64(100000)
+		if c.ranged {
+			min, max = c.minInt, c.maxInt
+		}
+		return strconv.AppendInt(dst, randInt(rnd, min, max), 10)
+
+	case DecimalType:
+		min, max := 0.0, 10000.0
+		if c.ranged {
+			min, max = c.min, c.max
+		}
+		d := NewDecimal(int64((min+rnd.Float64()*(max-min))*100), 2)
+		if column.ImpliedDecimals > 0 {
+			return d.appendImplied(dst, column.ImpliedDecimals)
+		}
+		return d.AppendFormat(dst)
+
+	case DateType, DateTimeType:
+		dates, err := column.dates()
+		if err != nil {
+			return dst
+		}
+		if rnd.Float64() < c.sentinelRate {
+			dst, _ = dates.Append(dst, sql.NullTime{})
+			return dst
+		}
+		t := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(rnd.Int63n(int64(6 * 365 * 24 * time.Hour))))
+		if column.Type == DateType {
+			t = t.Truncate(24 * time.Hour)
+		} else {
+			t = t.Truncate(time.Second)
+		}
+		dst, _ = dates.Append(dst, sql.NullTime{Time: t, Valid: true})
+		return dst
+
+	case PeriodType:
+		return Period{Year: 2015 + rnd.Intn(6), Month: time.Month(1 + rnd.Intn(12))}.AppendFormat(dst)
+
+	case BoolType:
+		return column.appendBool(dst, rnd.Intn(2) == 0)
+
+	case EnumType:
+		var codes []string
+		if column.codes != nil {
+			for _, v := range column.codes.values {
+				codes = append(codes, v.Code)
+			}
+		} else {
+			codes = column.Values
+		}
+		if len(codes) == 0 {
+			return dst
+		}
+		return append(dst, codes[rnd.Intn(len(codes))]...)
+	}
+
+	n := c.minLen + rnd.Intn(c.maxLen-c.minLen+1)
+	if rnd.Float64() < g.GarbageRate {
+		for i := 0; i < n; i++ {
+			b := byte(1 + rnd.Intn(255))
+			if strings.IndexByte(avoid, b) != -1 {
+				b = '?'
+			}
+			dst = append(dst, b)
+		}
+		return dst
+	}
+
+	start := len(dst)
+	for len(dst)-start < n {
+		if len(dst) > start {
+			dst = append(dst, ' ')
+		}
+		dst = append(dst, words[rnd.Intn(len(words))]...)
+	}
+	dst = dst[:start+n]
+
+	if column.MultiLine && !c.last && rnd.Float64() < g.MultiLineRate {
+		// break the line at one of the spaces between words
+		var spaces []int
+		for i := start + 1; i < len(dst)-1; i++ {
+			if dst[i] == ' ' {
+				spaces = append(spaces, i)
+			}
+		}
+		if len(spaces) > 0 {
+			dst[spaces[rnd.Intn(len(spaces))]] = '\n'
+		}
+	}
+	return dst
+}
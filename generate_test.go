@@ -0,0 +1,209 @@
+package billdsv
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+	"github.com/pkg/errors"
+)
+
+func TestGeneratorSchema(t *testing.T) {
+	s := carBonusSchema()
+	g := &Generator{Schema: s, Records: 500, Heading: true, Seed: 1, NullRate: 0.5, MultiLineRate: 0.5}
+
+	var buf bytes.Buffer
+	if err := g.Generate(NewWriter(&buf)); err != nil {
+		t.Fatal(err)
+	}
+
+	cr := NewSchemaReader(bytes.NewReader(buf.Bytes()), s, DefaultBufferSize)
+	cr.SkipHeading = true
+
+	records, nulls, multiLine := 0, 0, 0
+	err := cr.ReadAll(func(row [][]byte) {
+		records++
+		if len(row[9]) == 0 {
+			nulls++
+		}
+		if bytes.IndexByte(row[9], '\n') != -1 {
+			multiLine++
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 500, records)
+	assert.Equal(t, s.Columns[0].Name, cr.Heading()[0])
+	if nulls < 200 || nulls > 300 {
+		t.Errorf("expected about 250 null notes, got %d", nulls)
+	}
+	if multiLine < 75 || multiLine > 175 {
+		t.Errorf("expected about 125 multi-line notes, got %d", multiLine)
+	}
+
+	// the same seed generates the same document
+	var again bytes.Buffer
+	if err := g.Generate(NewWriter(&again)); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, buf.String(), again.String())
+}
+
+func TestGeneratorGarbage(t *testing.T) {
+	s := &Schema{Columns: []Column{
+		{Name: "Notes", Type: StringType, MultiLine: true},
+		{Name: "Number", Type: IntType},
+	}}
+	g := &Generator{Schema: s, Records: 200, Seed: 2, GarbageRate: 1, MultiLineRate: 1}
+
+	document := g.Reader()
+	defer document.Close()
+
+	cr := NewReader(document, 2, DefaultBufferSize)
+	records := 0
+	err := cr.ReadAll(func(row [][]byte) {
+		records++
+		assert.Equal(t, 2, len(row))
+		if _, err := s.Row(row).Int("Number"); err != nil {
+			t.Error(err)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, records)
+}
+
+func TestGeneratorProfile(t *testing.T) {
+	original := &Generator{Schema: carBonusSchema(), Records: 1000, Heading: true, Seed: 3, NullRate: 0.2}
+	document := original.Reader()
+	defer document.Close()
+
+	b, err := ioutil.ReadAll(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profile := func(document []byte) *Profile {
+		cr := NewReader(bytes.NewReader(document), 0, DefaultBufferSize)
+		cr.SkipHeading = true
+		p, err := Profiler{}.Profile(cr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	p := profile(b)
+
+	var buf bytes.Buffer
+	g := &Generator{Profile: p, Records: 1000, Heading: true, Seed: 4}
+	if err := g.Generate(NewWriter(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	synthetic := profile(buf.Bytes())
+
+	assert.Equal(t, p.Records, synthetic.Records)
+	for i, c := range p.Columns {
+		s := synthetic.Columns[i]
+		assert.Equal(t, c.Name, s.Name)
+		if c.Type != s.Type {
+			t.Errorf("%s: expected %s, got %s", c.Name, c.Type, s.Type)
+		}
+		if d := c.Nulls - s.Nulls; d < -60 || d > 60 {
+			t.Errorf("%s: expected about %d nulls, got %d", c.Name, c.Nulls, s.Nulls)
+		}
+	}
+}
+
+func TestGeneratorProfileUniqueValues(t *testing.T) {
+	document := `Email|Name|Status
+bob@x.com|Bob Smith|Live
+amy@y.com|Amy Jones|Live
+cat@z.com|Cat Brown|Closed
+dan@w.com|Dan Green|Live
+`
+	cr := NewReader(strings.NewReader(document), 0, DefaultBufferSize)
+	cr.SkipHeading = true
+	p, err := Profiler{}.Profile(cr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	g := &Generator{Profile: p, Records: 1000, Seed: 5}
+	if err := g.Generate(NewWriter(&buf)); err != nil {
+		t.Fatal(err)
+	}
+
+	// values seen once are never repeated, those seen more often may be
+	for _, value := range []string{"bob@x.com", "amy@y.com", "cat@z.com", "dan@w.com", "Bob Smith", "Amy Jones", "Cat Brown", "Dan Green", "Closed"} {
+		if strings.Contains(buf.String(), value) {
+			t.Errorf("expected %q not to be generated", value)
+		}
+	}
+	assert.Equal(t, true, strings.Contains(buf.String(), "Live"))
+}
+
+func TestGeneratorReaderClose(t *testing.T) {
+	g := &Generator{Schema: carBonusSchema(), Records: 100000, Seed: 4}
+	document := g.Reader()
+
+	// the reader stops early, closing the document stops generating it
+	cr := NewReader(document, 0, DefaultBufferSize)
+	stop := errors.New("stop")
+	err := cr.readAll(func([][]byte) error { return stop })
+	assert.Equal(t, stop, err)
+
+	assert.Equal(t, nil, document.Close())
+	_, err = document.Read(make([]byte, 1))
+	assert.Equal(t, io.ErrClosedPipe, err)
+}
+
+func TestGeneratorProfileRanges(t *testing.T) {
+	p := &Profile{Records: 1, Columns: []ColumnProfile{
+		{Column: Column{Name: "Id", Type: IntType}, Min: "1", Max: "9223372036854775807"},
+		{Column: Column{Name: "Whole", Type: IntType}, Min: "-9223372036854775807", Max: "9223372036854775807"},
+		{Column: Column{Name: "Edited", Type: IntType}, Min: "10", Max: "5"},
+		{Column: Column{Name: "Exact", Type: IntType}, Min: "9007199254740993", Max: "9007199254740993"},
+	}}
+
+	var buf bytes.Buffer
+	g := &Generator{Profile: p, Records: 100, Seed: 5}
+	if err := g.Generate(NewWriter(&buf)); err != nil {
+		t.Fatal(err)
+	}
+
+	cr := NewSchemaReader(&buf, p.Schema(), DefaultBufferSize)
+	err := cr.ReadAll(func(row [][]byte) {
+		id, _ := strconv.ParseInt(string(row[0]), 10, 64)
+		edited, _ := strconv.ParseInt(string(row[2]), 10, 64)
+		assert.Equal(t, true, id >= 1)
+		assert.Equal(t, true, edited >= 5 && edited <= 10)
+		assert.Equal(t, "9007199254740993", string(row[3]))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func BenchmarkReaderSynthetic(b *testing.B) {
+	s := carBonusSchema()
+	r := make([]*Reader, b.N)
+	for i := 0; i < b.N; i++ {
+		g := &Generator{Schema: s, Records: 100000, Seed: int64(i), NullRate: 0.1, MultiLineRate: 0.05}
+		r[i] = NewSchemaReader(g.Reader(), s, DefaultBufferSize)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r[i].ReadAll(func(row [][]byte) {
+			e = row
+		})
+	}
+}
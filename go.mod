@@ -4,6 +4,7 @@ require (
 	github.com/bmizerany/assert v0.0.0-20160611221934-b7ed37b82869
 	github.com/kr/pretty v0.1.0 // indirect
 	github.com/pkg/errors v0.8.1
+	gopkg.in/yaml.v2 v2.2.8
 )
 
 go 1.13
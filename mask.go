@@ -0,0 +1,199 @@
+package billdsv
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Masking is how a sensitive value is masked.
+type Masking int
+
+const (
+	// Redact replaces values with the masker's Redaction.
+	Redact Masking = iota
+	// Hash replaces values with a keyed HMAC-SHA256 of them, the same value
+	// always hashing the same so masked documents can still be joined.
+	Hash
+	// Mask replaces every letter and digit with another derived from a keyed
+	// HMAC of the value, keeping its case, punctuation and length, so
+	// "titteringtonamy@gmail.com" may become "qbzrkvlexhdpaow@ktnco.pre".
+	// Like Hash the same value is always masked the same.
+	Mask
+)
+
+// ParseMasking parses "redact", "hash" or "mask".
+func ParseMasking(s string) (Masking, error) {
+	switch s {
+	case "redact":
+		return Redact, nil
+	case "hash":
+		return Hash, nil
+	case "mask":
+		return Mask, nil
+	}
+	return 0, errors.Errorf("%q isn't redact, hash or mask", s)
+}
+
+func (m Masking) String() string {
+	switch m {
+	case Redact:
+		return "redact"
+	case Hash:
+		return "hash"
+	case Mask:
+		return "mask"
+	}
+	return "Masking(" + strconv.Itoa(int(m)) + ")"
+}
+
+// DefaultRedaction replaces redacted values when a masker has no Redaction.
+var DefaultRedaction = "REDACTED"
+
+// hashSize is the number of bytes of the HMAC kept by Hash, written as twice
+// as many hex digits.
+const hashSize = 16
+
+// Masker masks the sensitive columns of records, such as names, email
+// addresses and phone numbers, as they're read so they never reach the
+// function passed to ReadAll. Empty values are left empty.
+//
+// Values are masked once the reader's schema has checked them, so the samples
+// in its Report and the value of a *ValidationError are not masked. Keep them
+// to the same environment as the document.
+type Masker struct {
+	// Key is the secret key of Hash and Mask, without it their output could
+	// be reversed by hashing likely values.
+	Key []byte
+
+	// Columns maps the columns to mask to how they're masked. Columns are
+	// named by the reader's schema or heading, or by their index when no
+	// column has the name. Columns the reader doesn't select are ignored.
+	Columns map[string]Masking
+
+	// Redaction replaces redacted values, DefaultRedaction when empty.
+	Redaction string
+
+	mac     hash.Hash
+	sum     []byte
+	scratch []byte
+}
+
+// ReadAll reads all records from r, masking the sensitive columns of each
+// before passing it to function.
+func (m *Masker) ReadAll(r *Reader, function func([][]byte)) error {
+	var columns map[int]Masking
+
+	return r.readAll(func(row [][]byte) error {
+		if columns == nil {
+			var err error
+			if columns, err = m.resolve(r); err != nil {
+				return err
+			}
+		}
+
+		for i, masking := range columns {
+			if i >= len(row) || len(row[i]) == 0 {
+				continue
+			}
+			m.scratch = m.Append(m.scratch[:0], row[i], masking)
+			row[i] = append(row[i][:0], m.scratch...)
+		}
+		function(row)
+		return nil
+	})
+}
+
+// resolve maps the masked columns to their indexes.
+func (m *Masker) resolve(r *Reader) (map[int]Masking, error) {
+	columns := make(map[int]Masking, len(m.Columns))
+	for name, masking := range m.Columns {
+		if (masking == Hash || masking == Mask) && len(m.Key) == 0 {
+			return nil, errors.Errorf("column %s can't be masked with %s without a key", name, masking)
+		}
+
+		i, err := r.column(name, "mask")
+		if err != nil {
+			return nil, err
+		}
+		if i = r.position(i); i != -1 {
+			columns[i] = masking
+		}
+	}
+	return columns, nil
+}
+
+// Append appends value masked as masking dictates to dst.
+func (m *Masker) Append(dst, value []byte, masking Masking) []byte {
+	switch masking {
+	case Hash:
+		var b [2 * hashSize]byte
+		hex.Encode(b[:], m.hmac(value, 0)[:hashSize])
+		return append(dst, b[:]...)
+
+	case Mask:
+		var (
+			stream  []byte
+			counter uint32
+		)
+		next := func(n byte) byte {
+			if len(stream) == 0 {
+				stream = m.hmac(value, counter)
+				counter++
+			}
+			b := stream[0]
+			stream = stream[1:]
+			return b % n
+		}
+
+		for i := 0; i < len(value); {
+			c := value[i]
+			switch {
+			case c >= '0' && c <= '9':
+				dst = append(dst, '0'+next(10))
+			case c >= 'a' && c <= 'z':
+				dst = append(dst, 'a'+next(26))
+			case c >= 'A' && c <= 'Z':
+				dst = append(dst, 'A'+next(26))
+			case c < utf8.RuneSelf:
+				dst = append(dst, c)
+			default:
+				// letters beyond ASCII, such as accents, become plain ones
+				_, size := utf8.DecodeRune(value[i:])
+				dst = append(dst, 'a'+next(26))
+				i += size
+				continue
+			}
+			i++
+		}
+		return dst
+	}
+
+	if m.Redaction == "" {
+		return append(dst, DefaultRedaction...)
+	}
+	return append(dst, m.Redaction...)
+}
+
+// hmac returns a block of the keyed HMAC of a value, it's only valid until
+// the next call.
+func (m *Masker) hmac(value []byte, block uint32) []byte {
+	if m.mac == nil {
+		m.mac = hmac.New(sha256.New, m.Key)
+	}
+	m.mac.Reset()
+	m.mac.Write(value)
+	if block > 0 {
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], block)
+		m.mac.Write(b[:])
+	}
+	m.sum = m.mac.Sum(m.sum[:0])
+	return m.sum
+}
@@ -0,0 +1,86 @@
+package billdsv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+const appointments = `Ref|Name|Phones|Email|Notes
+7860442|Mr S & Mrs A Titterington|07510044409, 01768885022|titteringtonamy@gmail.com|Customer has requested to book later
+7860462|Mr S & Mrs A Titterington||TitteringtonAmy@Gmail.com|
+`
+
+func TestMasker(t *testing.T) {
+	cr := NewReader(strings.NewReader(appointments), 0, DefaultBufferSize)
+	cr.SkipHeading = true
+
+	m := &Masker{
+		Key: []byte("secret"),
+		Columns: map[string]Masking{
+			"Name":   Hash,
+			"Phones": Mask,
+			"Email":  Mask,
+			"Notes":  Redact,
+		},
+	}
+
+	got := [][]string{}
+	err := m.ReadAll(cr, func(row [][]byte) {
+		values := make([]string, len(row))
+		for i, v := range row {
+			values[i] = string(v)
+		}
+		got = append(got, values)
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(got))
+
+	// hashes are the same for the same value so they can still be joined
+	assert.Equal(t, 32, len(got[0][1]))
+	assert.Equal(t, got[0][1], got[1][1])
+	assert.NotEqual(t, "Mr S & Mrs A Titterington", got[0][1])
+
+	// masks keep the format of the value
+	assert.Equal(t, len("07510044409, 01768885022"), len(got[0][2]))
+	assert.Equal(t, ", ", got[0][2][11:13])
+	assert.NotEqual(t, "07510044409", got[0][2][:11])
+	assert.Equal(t, "", got[1][2])
+	assert.Equal(t, 15, strings.Index(got[0][3], "@"))
+	assert.Equal(t, 21, strings.LastIndex(got[0][3], "."))
+	assert.Equal(t, true, got[1][3][0] >= 'A' && got[1][3][0] <= 'Z')
+	assert.NotEqual(t, got[0][3], got[1][3])
+
+	assert.Equal(t, []string{"REDACTED", ""}, []string{got[0][4], got[1][4]})
+	assert.Equal(t, "7860442", got[0][0])
+
+	// a different key masks differently
+	other := &Masker{Key: []byte("other")}
+	assert.NotEqual(t, got[0][1], string(other.Append(nil, []byte("Mr S & Mrs A Titterington"), Hash)))
+	assert.Equal(t, got[0][1], string(m.Append(nil, []byte("Mr S & Mrs A Titterington"), Hash)))
+}
+
+func TestMaskerErrors(t *testing.T) {
+	cr := NewReader(strings.NewReader(appointments), 0, DefaultBufferSize)
+	cr.SkipHeading = true
+	err := (&Masker{Columns: map[string]Masking{"Email": Hash}}).ReadAll(cr, func([][]byte) {})
+	assert.Equal(t, "column Email can't be masked with hash without a key", err.Error())
+
+	cr = NewReader(strings.NewReader(appointments), 0, DefaultBufferSize)
+	cr.SkipHeading = true
+	err = (&Masker{Columns: map[string]Masking{"Mobile": Redact}}).ReadAll(cr, func([][]byte) {})
+	assert.Equal(t, `no column named "Mobile" to mask`, err.Error())
+
+	// without a heading columns are named by index
+	cr = NewReader(strings.NewReader("7860442|Titterington\n"), 0, DefaultBufferSize)
+	got := ""
+	err = (&Masker{Columns: map[string]Masking{"1": Redact}, Redaction: "-"}).ReadAll(cr, func(row [][]byte) {
+		got = string(row[0]) + "|" + string(row[1])
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "7860442|-", got)
+
+	_, err = ParseMasking("scramble")
+	assert.NotEqual(t, nil, err)
+}
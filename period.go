@@ -0,0 +1,98 @@
+package billdsv
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Period is a billing period, a month of a year, written by Bill as "2015/09"
+// in columns such as CrPeriod.
+type Period struct {
+	Year  int
+	Month time.Month
+}
+
+// ParsePeriod parses a period written as "YYYY/MM", "YYYY-MM" also being
+// accepted.
+func ParsePeriod(value []byte) (Period, error) {
+	if len(value) != 7 || (value[4] != '/' && value[4] != '-') {
+		return Period{}, errors.Errorf("%q isn't a period, it should be written as YYYY/MM", value)
+	}
+
+	year, err := strconv.Atoi(string(value[:4]))
+	if err != nil {
+		return Period{}, errors.Errorf("%q isn't a period, it should be written as YYYY/MM", value)
+	}
+	month, err := strconv.Atoi(string(value[5:]))
+	if err != nil || month < 1 || month > 12 {
+		return Period{}, errors.Errorf("%q isn't a period, the month should be between 01 and 12", value)
+	}
+
+	return Period{Year: year, Month: time.Month(month)}, nil
+}
+
+// PeriodOf returns the period t falls in.
+func PeriodOf(t time.Time) Period {
+	return Period{Year: t.Year(), Month: t.Month()}
+}
+
+// IsZero reports whether p is the zero Period.
+func (p Period) IsZero() bool {
+	return p.Year == 0 && p.Month == 0
+}
+
+// Start returns the first instant of the period in loc.
+func (p Period) Start(loc *time.Location) time.Time {
+	return time.Date(p.Year, p.Month, 1, 0, 0, 0, 0, loc)
+}
+
+// End returns the first instant after the period in loc.
+func (p Period) End(loc *time.Location) time.Time {
+	return p.AddMonths(1).Start(loc)
+}
+
+// Contains reports whether t falls within the period, in t's location.
+func (p Period) Contains(t time.Time) bool {
+	return PeriodOf(t) == p
+}
+
+// AddMonths returns the period n months later, or earlier when n is negative.
+func (p Period) AddMonths(n int) Period {
+	return PeriodOf(time.Date(p.Year, p.Month+time.Month(n), 1, 0, 0, 0, 0, time.UTC))
+}
+
+// Before reports whether p is before o.
+func (p Period) Before(o Period) bool {
+	return p.Year < o.Year || (p.Year == o.Year && p.Month < o.Month)
+}
+
+// String formats the period as "YYYY/MM".
+func (p Period) String() string {
+	return string(p.AppendFormat(nil))
+}
+
+// AppendFormat appends the period formatted as "YYYY/MM" to dst.
+func (p Period) AppendFormat(dst []byte) []byte {
+	for y := 1000; y > 1 && p.Year < y; y /= 10 {
+		dst = append(dst, '0')
+	}
+	dst = strconv.AppendInt(dst, int64(p.Year), 10)
+	dst = append(dst, '/')
+	if p.Month < 10 {
+		dst = append(dst, '0')
+	}
+	return strconv.AppendInt(dst, int64(p.Month), 10)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (p Period) MarshalText() ([]byte, error) {
+	return p.AppendFormat(nil), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (p *Period) UnmarshalText(b []byte) (err error) {
+	*p, err = ParsePeriod(b)
+	return err
+}
@@ -0,0 +1,76 @@
+package billdsv
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestParsePeriod(t *testing.T) {
+	p, err := ParsePeriod([]byte("2015/09"))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, Period{2015, time.September}, p)
+	assert.Equal(t, "2015/09", p.String())
+
+	p, err = ParsePeriod([]byte("2015-11"))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, Period{2015, time.November}, p)
+
+	for _, value := range []string{"", "2015/13", "2015/9", "15/09", "2015.09"} {
+		_, err = ParsePeriod([]byte(value))
+		assert.NotEqual(t, nil, err)
+	}
+}
+
+func TestPeriodArithmetic(t *testing.T) {
+	p := Period{2015, time.November}
+
+	assert.Equal(t, Period{2016, time.January}, p.AddMonths(2))
+	assert.Equal(t, Period{2014, time.December}, p.AddMonths(-11))
+	assert.Equal(t, true, p.Before(p.AddMonths(1)))
+	assert.Equal(t, false, p.Before(p))
+	assert.Equal(t, "0015/01", Period{15, time.January}.String())
+
+	london, err := LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, time.Date(2015, 11, 1, 0, 0, 0, 0, london), p.Start(london))
+	assert.Equal(t, time.Date(2015, 12, 1, 0, 0, 0, 0, london), p.End(london))
+	assert.Equal(t, true, p.Contains(time.Date(2015, 11, 30, 23, 59, 59, 0, london)))
+}
+
+func TestRowDecodePeriod(t *testing.T) {
+	s := carBonusSchema()
+	s.Columns[1] = Column{Name: "CrPeriod", Type: PeriodType}
+
+	cr := NewSchemaReader(strings.NewReader(carBonuses), s, DefaultBufferSize)
+	cr.SkipHeading = true
+
+	var v struct {
+		Period  Period  `dsv:"CrPeriod"`
+		Pointer *Period `dsv:"CrPeriod"`
+	}
+
+	got := []Period{}
+	err := cr.ReadAll(func(fields [][]byte) {
+		if err := s.Row(fields).Decode(&v); err != nil {
+			t.Error(err)
+		}
+		assert.Equal(t, v.Period, *v.Pointer)
+		got = append(got, v.Period)
+
+		record, err := s.Encode(nil, struct {
+			Period Period `dsv:"CrPeriod"`
+		}{v.Period})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, string(fields[1]), string(record[1]))
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, []Period{{2015, time.September}, {2015, time.November}}, got)
+}
@@ -0,0 +1,147 @@
+package billdsv
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// Pipeline processes records concurrently while committing their results in
+// the order they were read, for work such as enrichment or publishing where
+// the output has to follow the document. Each record is copied into a pooled
+// buffer before it's handed to a worker, so memory is bounded by Depth and
+// reading waits for the workers once that many records are in flight.
+type Pipeline struct {
+	// Workers is the number of records processed at once, GOMAXPROCS when
+	// zero.
+	Workers int
+
+	// Depth is the greatest number of records read but not yet committed,
+	// twice the number of workers when zero.
+	Depth int
+
+	pool sync.Pool
+}
+
+// job is a record copied for processing along with its result.
+type job struct {
+	record Record
+	buf    []byte
+	result interface{}
+	err    error
+	done   chan struct{}
+}
+
+// ReadAll reads all records from r and passes each to process on one of the
+// workers, then passes the record and its result to commit one at a time in
+// the order the records were read. The record is only valid until commit
+// returns, at which point its buffer is reused.
+//
+// The first error returned by process or commit, or met reading, is returned
+// once the work in flight has stopped. It cancels the context passed to
+// process and no further results are committed.
+func (p *Pipeline) ReadAll(ctx context.Context, r *Reader, process func(ctx context.Context, record Record) (interface{}, error), commit func(record Record, result interface{}) error) error {
+	workers := p.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	depth := p.Depth
+	if depth <= 0 {
+		depth = 2 * workers
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		once     sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	jobs := make(chan *job, depth)
+	order := make(chan *job, depth)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if j.err = ctx.Err(); j.err == nil {
+					j.result, j.err = process(ctx, j.record)
+				}
+				j.done <- struct{}{}
+			}
+		}()
+	}
+
+	// results are committed in the order the records were queued, waiting
+	// for each to be processed in turn
+	committed := make(chan struct{})
+	go func() {
+		defer close(committed)
+		for j := range order {
+			<-j.done
+			if ctx.Err() == nil {
+				if j.err != nil {
+					fail(j.err)
+				} else if err := commit(j.record, j.result); err != nil {
+					fail(err)
+				}
+			}
+			p.release(r, j)
+		}
+	}()
+
+	err := r.readAll(func(row [][]byte) error {
+		j := p.acquire(row)
+		select {
+		case order <- j:
+		case <-ctx.Done():
+			p.release(r, j)
+			return ctx.Err()
+		}
+		jobs <- j
+		return nil
+	})
+	if err != nil {
+		fail(err)
+	}
+
+	close(jobs)
+	close(order)
+	wg.Wait()
+	<-committed
+
+	if firstErr == nil {
+		// the caller's context was cancelled after the last record was read
+		return ctx.Err()
+	}
+	return firstErr
+}
+
+// acquire copies a record into a job from the pool.
+func (p *Pipeline) acquire(row [][]byte) *job {
+	j, _ := p.pool.Get().(*job)
+	if j == nil {
+		j = &job{done: make(chan struct{}, 1)}
+	}
+	j.buf, j.record = copyRecord(j.buf, j.record, row)
+	return j
+}
+
+// release returns a job to the pool once its result has been committed,
+// poisoning its buffer first if r.Poison is set.
+func (p *Pipeline) release(r *Reader, j *job) {
+	if r.Poison {
+		poison(j.buf)
+	}
+	j.result, j.err = nil, nil
+	p.pool.Put(j)
+}
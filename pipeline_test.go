@@ -0,0 +1,111 @@
+package billdsv
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+	"github.com/pkg/errors"
+)
+
+func numbered(n int) string {
+	sb := strings.Builder{}
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, "%d|record %d\n", i, i)
+	}
+	return sb.String()
+}
+
+func TestPipelineOrder(t *testing.T) {
+	cr := NewReader(strings.NewReader(numbered(200)), 2, DefaultBufferSize)
+
+	got := []int{}
+	p := &Pipeline{Workers: 4, Depth: 8}
+	err := p.ReadAll(context.Background(), cr, func(ctx context.Context, record Record) (interface{}, error) {
+		n, err := strconv.Atoi(string(record[0]))
+		// later records finish first
+		time.Sleep(time.Duration(n%5) * time.Millisecond)
+		return n, err
+	}, func(record Record, result interface{}) error {
+		assert.Equal(t, fmt.Sprintf("record %d", result), string(record[1]))
+		got = append(got, result.(int))
+		return nil
+	})
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, 200, len(got))
+	for i, n := range got {
+		if n != i {
+			t.Fatalf("expected record %d to be committed at %d, got %d", i, i, n)
+		}
+	}
+}
+
+func TestPipelineProcessError(t *testing.T) {
+	cr := NewReader(strings.NewReader(numbered(1000)), 2, DefaultBufferSize)
+
+	committed := 0
+	p := &Pipeline{Workers: 4}
+	err := p.ReadAll(context.Background(), cr, func(ctx context.Context, record Record) (interface{}, error) {
+		if string(record[0]) == "10" {
+			return nil, errors.New("enrichment failed")
+		}
+		return nil, nil
+	}, func(Record, interface{}) error {
+		committed++
+		return nil
+	})
+	assert.Equal(t, "enrichment failed", err.Error())
+	assert.Equal(t, 10, committed)
+}
+
+func TestPipelineCommitError(t *testing.T) {
+	cr := NewReader(strings.NewReader(numbered(1000)), 2, DefaultBufferSize)
+
+	processed := 0
+	p := &Pipeline{Workers: 1, Depth: 2}
+	err := p.ReadAll(context.Background(), cr, func(ctx context.Context, record Record) (interface{}, error) {
+		processed++
+		return nil, nil
+	}, func(record Record, _ interface{}) error {
+		return errors.New("publish failed")
+	})
+	assert.Equal(t, "publish failed", err.Error())
+
+	// reading stopped a few records past the failure
+	if processed > 10 {
+		t.Errorf("expected processing to be cancelled, %d records were processed", processed)
+	}
+}
+
+func TestPipelineReadError(t *testing.T) {
+	cr := NewReader(strings.NewReader("1|a\n2|b|c\n"), 2, DefaultBufferSize)
+
+	p := &Pipeline{}
+	err := p.ReadAll(context.Background(), cr, func(ctx context.Context, record Record) (interface{}, error) {
+		return nil, nil
+	}, func(Record, interface{}) error {
+		return nil
+	})
+	assert.Equal(t, "on row 1, expected 2 fields but read an extra field", err.Error())
+}
+
+func TestPipelineCancel(t *testing.T) {
+	cr := NewReader(strings.NewReader(numbered(1000)), 2, DefaultBufferSize)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pipeline{Workers: 2}
+	err := p.ReadAll(ctx, cr, func(ctx context.Context, record Record) (interface{}, error) {
+		if string(record[0]) == "5" {
+			cancel()
+		}
+		return nil, nil
+	}, func(Record, interface{}) error {
+		return nil
+	})
+	assert.Equal(t, context.Canceled, err)
+}
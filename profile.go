@@ -0,0 +1,370 @@
+package billdsv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ValueCount is a value and the number of times it was seen.
+type ValueCount struct {
+	Value string `json:"value" yaml:"value"`
+	Count int    `json:"count" yaml:"count"`
+}
+
+// ColumnProfile is the column proposed for a schema along with the statistics
+// it was inferred from. The statistics are ignored when the profile is read
+// back as a schema.
+type ColumnProfile struct {
+	Column `yaml:",inline"`
+
+	// Nulls is the number of empty values.
+	Nulls int `json:"nulls" yaml:"nulls"`
+	// Distinct is the number of distinct values, it stops counting at the
+	// profiler's DistinctLimit.
+	Distinct int `json:"distinct" yaml:"distinct"`
+	// MinLength is the length in characters of the shortest non-empty value.
+	MinLength int `json:"minLength" yaml:"minLength"`
+	// Min and Max are the range of numeric columns.
+	Min string `json:"min,omitempty" yaml:"min,omitempty"`
+	Max string `json:"max,omitempty" yaml:"max,omitempty"`
+	// Top are the most frequent values.
+	Top []ValueCount `json:"top,omitempty" yaml:"top,omitempty"`
+}
+
+// Profile is a proposed schema for a document along with statistics about
+// each column.
+type Profile struct {
+	Records int             `json:"records" yaml:"records"`
+	Columns []ColumnProfile `json:"columns" yaml:"columns"`
+}
+
+// Schema returns the proposed schema.
+func (p *Profile) Schema() *Schema {
+	s := &Schema{Columns: make([]Column, len(p.Columns))}
+	for i, c := range p.Columns {
+		s.Columns[i] = c.Column
+	}
+	return s
+}
+
+// WriteJSON writes the profile as indented JSON.
+func (p *Profile) WriteJSON(w io.Writer) error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// WriteYAML writes the profile as YAML.
+func (p *Profile) WriteYAML(w io.Writer) error {
+	b, err := yaml.Marshal(p)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// Profiler infers a schema from sample data for documents without a data
+// dictionary.
+type Profiler struct {
+	// Limit is the number of records to sample, zero reads the whole
+	// document.
+	Limit int
+
+	// DistinctLimit is the number of distinct values counted per column,
+	// zero defaults to 1000.
+	DistinctLimit int
+
+	// EnumLimit is the most distinct values a text column may have to be
+	// proposed as an enum, zero never proposes enums.
+	EnumLimit int
+
+	// TopValues is the number of most frequent values kept per column, zero
+	// defaults to 10.
+	TopValues int
+}
+
+// columnStats accumulates the statistics of a column. The candidate flags
+// start out set and are cleared by the first value that rules them out.
+type columnStats struct {
+	values   int
+	nulls    int
+	minLen   int
+	maxLen   int
+	lines    bool
+	boolean  bool
+	integer  bool
+	decimal  bool
+	period   bool
+	date     bool
+	layouts  []int
+	min, max Decimal
+	counts   map[string]int
+	capped   bool
+}
+
+// Profile reads records from r through ReadAll and proposes a schema for
+// them. Column names come from the heading when SkipHeading is set.
+func (p Profiler) Profile(r *Reader) (*Profile, error) {
+	distinctLimit := p.DistinctLimit
+	if distinctLimit == 0 {
+		distinctLimit = 1000
+	}
+
+	var (
+		records int
+		stats   []*columnStats
+	)
+
+	err := r.readAll(func(row [][]byte) error {
+		if stats == nil {
+			stats = make([]*columnStats, len(row))
+			for i := range stats {
+				stats[i] = &columnStats{
+					boolean: true,
+					integer: true,
+					decimal: true,
+					period:  true,
+					date:    true,
+					layouts: make([]int, len(DefaultDateLayouts)),
+					counts:  map[string]int{},
+				}
+			}
+		}
+
+		records++
+		for i, value := range row {
+			stats[i].add(value, distinctLimit)
+		}
+
+		if p.Limit > 0 && records >= p.Limit {
+			return errStop
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &Profile{Records: records, Columns: make([]ColumnProfile, len(stats))}
+	heading := r.Heading()
+	for i, s := range stats {
+		name := fmt.Sprintf("Column%d", i+1)
+		if selected := r.Selected(); selected != nil {
+			name = fmt.Sprintf("Column%d", selected[i]+1)
+			if selected[i] < len(heading) {
+				name = heading[selected[i]]
+			}
+		} else if len(heading) == len(stats) {
+			name = heading[i]
+		}
+		profile.Columns[i] = s.profile(name, p)
+	}
+	return profile, nil
+}
+
+func (s *columnStats) add(value []byte, distinctLimit int) {
+	if len(value) == 0 {
+		s.nulls++
+		return
+	}
+	s.values++
+
+	n := utf8.RuneCount(value)
+	if s.values == 1 || n < s.minLen {
+		s.minLen = n
+	}
+	if n > s.maxLen {
+		s.maxLen = n
+	}
+	if bytes.ContainsAny(value, "\r\n") {
+		s.lines = true
+	}
+
+	if _, ok := s.counts[string(value)]; ok || len(s.counts) < distinctLimit {
+		s.counts[string(value)]++
+	} else {
+		s.capped = true
+	}
+
+	if s.boolean {
+		switch strings.ToLower(string(value)) {
+		case "y", "yes", "n", "no", "true", "false":
+		default:
+			s.boolean = false
+		}
+	}
+
+	if s.integer || s.decimal {
+		d, integer, decimal := isNumber(value)
+		s.integer = s.integer && integer
+		s.decimal = s.decimal && decimal
+		if decimal {
+			if s.values == 1 || d.Cmp(s.min) < 0 {
+				s.min = d
+			}
+			if s.values == 1 || d.Cmp(s.max) > 0 {
+				s.max = d
+			}
+		}
+	}
+
+	if s.period {
+		_, err := ParsePeriod(value)
+		s.period = err == nil
+	}
+
+	if s.date {
+		// values may be written in a mix of layouts, as long as each matches
+		// one of them the column holds dates
+		matched := false
+		for i, layout := range DefaultDateLayouts {
+			if _, err := time.Parse(layout, string(value)); err == nil {
+				s.layouts[i]++
+				matched = true
+			}
+		}
+		s.date = matched
+	}
+}
+
+func (s *columnStats) profile(name string, p Profiler) ColumnProfile {
+	c := ColumnProfile{
+		Column: Column{
+			Name:      name,
+			Type:      StringType,
+			Nullable:  s.nulls > 0,
+			MaxLength: s.maxLen,
+			MultiLine: s.lines,
+		},
+		Nulls:     s.nulls,
+		Distinct:  len(s.counts),
+		MinLength: s.minLen,
+	}
+
+	if s.values == 0 {
+		return c
+	}
+
+	switch {
+	case s.boolean:
+		c.Type = BoolType
+	case s.integer:
+		c.Type = IntType
+	case s.decimal:
+		c.Type = DecimalType
+	case s.period:
+		c.Type = PeriodType
+	case s.date:
+		// the most common layout first followed by the others seen
+		layouts := []string{}
+		for i, layout := range DefaultDateLayouts {
+			if s.layouts[i] > 0 {
+				layouts = append(layouts, layout)
+			}
+		}
+		sort.SliceStable(layouts, func(i, j int) bool {
+			return s.hits(layouts[i]) > s.hits(layouts[j])
+		})
+
+		c.Type = DateType
+		if strings.Contains(layouts[0], "15") {
+			c.Type = DateTimeType
+		}
+		c.Layout = layouts[0]
+		if len(layouts) > 1 {
+			c.Layouts = layouts[1:]
+		}
+	case p.EnumLimit > 0 && !s.capped && len(s.counts) <= p.EnumLimit && s.values >= 2*len(s.counts):
+		c.Type = EnumType
+		for v := range s.counts {
+			c.Values = append(c.Values, v)
+		}
+		sort.Strings(c.Values)
+	}
+
+	if c.Type == IntType || c.Type == DecimalType {
+		c.Min = s.min.String()
+		c.Max = s.max.String()
+	}
+
+	top := p.TopValues
+	if top == 0 {
+		top = 10
+	}
+	for v, n := range s.counts {
+		c.Top = append(c.Top, ValueCount{Value: v, Count: n})
+	}
+	sort.Slice(c.Top, func(i, j int) bool {
+		if c.Top[i].Count != c.Top[j].Count {
+			return c.Top[i].Count > c.Top[j].Count
+		}
+		return c.Top[i].Value < c.Top[j].Value
+	})
+	if len(c.Top) > top {
+		c.Top = c.Top[:top]
+	}
+
+	return c
+}
+
+// hits returns the number of values that matched a layout.
+func (s *columnStats) hits(layout string) int {
+	for i, l := range DefaultDateLayouts {
+		if l == layout {
+			return s.layouts[i]
+		}
+	}
+	return 0
+}
+
+// isNumber reports whether a value is an integer or a decimal, returning it
+// as a decimal. Values with leading zeros, like Bill's executive IDs, are
+// identifiers rather than numbers so they're neither, as are values too large
+// to read as an int64 or a Decimal.
+func isNumber(value []byte) (d Decimal, integer, decimal bool) {
+	digits := value
+	if digits[0] == '-' || digits[0] == '+' {
+		digits = digits[1:]
+	}
+
+	point := bytes.IndexByte(digits, '.')
+	whole := digits
+	if point != -1 {
+		whole = digits[:point]
+	}
+	if len(whole) == 0 || (len(whole) > 1 && whole[0] == '0') {
+		return d, false, false
+	}
+
+	for i, c := range digits {
+		if c == '.' && i == point && i != len(digits)-1 {
+			continue
+		}
+		if c < '0' || c > '9' {
+			return d, false, false
+		}
+	}
+
+	d, err := ParseDecimal(value)
+	if err != nil {
+		return d, false, false
+	}
+	if point == -1 {
+		_, err := strconv.ParseInt(string(value), 10, 64)
+		integer = err == nil
+	}
+	return d, integer, true
+}
@@ -0,0 +1,138 @@
+package billdsv
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestProfiler(t *testing.T) {
+	cr := NewReader(strings.NewReader(carBonuses+"340|2016/01|1155250|006310|No|-150.50|No|0|2016-01-04|Repaid\nin full|Scheme2||0|0|0|1899-12-30|1899-12-30\n"), 0, DefaultBufferSize)
+	cr.SkipHeading = true
+
+	p, err := Profiler{EnumLimit: 2}.Profile(cr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 3, p.Records)
+
+	types := map[string]Type{}
+	for _, c := range p.Columns {
+		types[c.Name] = c.Type
+	}
+	assert.Equal(t, map[string]Type{
+		"CrNumber":         IntType,
+		"CrPeriod":         PeriodType,
+		"CrCarBonusID":     IntType,
+		"CrCBExecID":       StringType,
+		"CrCBRepayment":    BoolType,
+		"CrCBRepaymentFee": DecimalType,
+		"CrCBBonusEligibl": BoolType,
+		"CrCBBonusAmount":  IntType,
+		"CrCBCommitted":    DateType,
+		"CrCBNotes":        StringType,
+		"CrCBScheme":       StringType,
+		"CrCBSpareC2":      StringType,
+		"CrCBBalance":      IntType,
+		"CrCBSpareNum1":    IntType,
+		"CrCBSpareNum2":    IntType,
+		"CrCBSpareDate1":   DateType,
+		"CrCBSpareDate2":   DateType,
+	}, types)
+
+	notes := p.Columns[9]
+	assert.Equal(t, true, notes.Nullable)
+	assert.Equal(t, true, notes.MultiLine)
+	assert.Equal(t, 2, notes.Nulls)
+	assert.Equal(t, 14, notes.MaxLength)
+
+	fee := p.Columns[5]
+	assert.Equal(t, "-150.50", fee.Min)
+	assert.Equal(t, "-150", fee.Max)
+	assert.Equal(t, []ValueCount{{"-150", 2}, {"-150.50", 1}}, fee.Top)
+
+	committed := p.Columns[8]
+	assert.Equal(t, "2006-01-02", committed.Layout)
+	assert.Equal(t, 3, committed.Distinct)
+}
+
+func TestProfilerEnum(t *testing.T) {
+	cr := NewReader(strings.NewReader("C|1\nE|2\nC|3\nE|4\nC|5\n"), 2, DefaultBufferSize)
+
+	p, err := Profiler{EnumLimit: 2}.Profile(cr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "Column1", p.Columns[0].Name)
+	assert.Equal(t, EnumType, p.Columns[0].Type)
+	assert.Equal(t, []string{"C", "E"}, p.Columns[0].Values)
+	assert.Equal(t, IntType, p.Columns[1].Type)
+}
+
+func TestProfilerLargeNumbers(t *testing.T) {
+	document := "Id|Amount|Small\n12345678901234567890123|12345678901234567890.5|9223372036854775807\n1|0.25|-9223372036854775807\n"
+	cr := NewReader(strings.NewReader(document), 0, DefaultBufferSize)
+	cr.SkipHeading = true
+
+	p, err := Profiler{}.Profile(cr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, StringType, p.Columns[0].Type)
+	assert.Equal(t, StringType, p.Columns[1].Type)
+	assert.Equal(t, IntType, p.Columns[2].Type)
+	assert.Equal(t, "-9223372036854775807", p.Columns[2].Min)
+	assert.Equal(t, "9223372036854775807", p.Columns[2].Max)
+
+	// the proposed schema reads the document it was inferred from
+	cr = NewSchemaReader(strings.NewReader(document), p.Schema(), DefaultBufferSize)
+	cr.SkipHeading = true
+	assert.Equal(t, nil, cr.ReadAll(func([][]byte) {}))
+}
+
+func TestProfilerLimit(t *testing.T) {
+	cr := NewReader(strings.NewReader(carBonuses), 0, DefaultBufferSize)
+	cr.SkipHeading = true
+
+	p, err := Profiler{Limit: 1}.Profile(cr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, p.Records)
+}
+
+func TestProfileRoundTrip(t *testing.T) {
+	cr := NewReader(strings.NewReader(carBonuses), 0, DefaultBufferSize)
+	cr.SkipHeading = true
+
+	p, err := Profiler{}.Profile(cr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, write := range []func(*Profile, *bytes.Buffer) error{
+		func(p *Profile, b *bytes.Buffer) error { return p.WriteYAML(b) },
+		func(p *Profile, b *bytes.Buffer) error { return p.WriteJSON(b) },
+	} {
+		b := &bytes.Buffer{}
+		if err := write(p, b); err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := ReadSchema(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, p.Schema().Columns, s.Columns)
+
+		cr := NewSchemaReader(strings.NewReader(carBonuses), s, DefaultBufferSize)
+		cr.SkipHeading = true
+		if err := cr.ReadAll(func(row [][]byte) {}); err != nil {
+			t.Error(err)
+		}
+	}
+}
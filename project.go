@@ -0,0 +1,122 @@
+package billdsv
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// project resolves Select into the indexes of the selected columns, once the
+// field count and heading are known.
+func (r *Reader) project() error {
+	if len(r.Select) == 0 {
+		return nil
+	}
+
+	r.selection = make([]int, len(r.Select))
+	r.selected = make([]bool, r.fields)
+	r.projected = make([][]byte, len(r.Select))
+
+	for k, name := range r.Select {
+		i, err := r.column(name, "select")
+		if err != nil {
+			return err
+		}
+		r.selection[k] = i
+		r.selected[i] = true
+	}
+
+	// the columns the rules of selected columns compare with are copied too,
+	// and every column when a rule checks the row with a function
+	r.copied = r.selected
+	if r.Schema != nil {
+		r.copied = append([]bool(nil), r.selected...)
+		for _, i := range r.selection {
+			if i >= len(r.Schema.Columns) {
+				continue
+			}
+			for _, rule := range r.Schema.Columns[i].Rules {
+				if j := r.Schema.Index(rule.After); rule.After != "" && j != -1 {
+					r.copied[j] = true
+				}
+				if rule.Check != nil {
+					for j := range r.copied {
+						r.copied[j] = true
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// unselected reports whether Select leaves a column out.
+func (r *Reader) unselected(column int) bool {
+	return r.selected != nil && column < len(r.selected) && !r.selected[column]
+}
+
+// column resolves the name of a column to its index, purpose describing what
+// it's for in errors.
+func (r *Reader) column(name, purpose string) (int, error) {
+	switch {
+	case r.Schema != nil:
+		if i := r.Schema.Index(name); i != -1 {
+			return i, nil
+		}
+	case r.heading != nil:
+		for i, h := range r.heading {
+			if h == name {
+				return i, nil
+			}
+		}
+	}
+
+	// columns without a name of their own are named by their index
+	n, err := strconv.Atoi(name)
+	if err != nil || n < 0 {
+		return -1, errors.Errorf("no column named %q to %s", name, purpose)
+	}
+	if n >= r.fields {
+		return -1, errors.Errorf("can't %s column %d of %d fields", purpose, n, r.fields)
+	}
+	return n, nil
+}
+
+// skips reports whether the values of a field are skipped rather than staged,
+// either because the record was filtered out or the field is only counted.
+func (r *Reader) skips(field int) bool {
+	if r.dropping {
+		return true
+	}
+	return r.copied != nil && field < len(r.copied) && !r.copied[field] &&
+		(r.filtering == nil || !r.filtering[field])
+}
+
+// Selected returns the indexes of the columns in each record passed to
+// ReadAll's function once reading has started, in the order of Select. It's
+// nil when Select isn't set and every column is passed on.
+func (r *Reader) Selected() []int {
+	return r.selection
+}
+
+// position returns the position of a column in the records passed to
+// ReadAll's function, -1 if it isn't selected.
+func (r *Reader) position(column int) int {
+	if r.selection == nil {
+		return column
+	}
+	for k, i := range r.selection {
+		if i == column {
+			return k
+		}
+	}
+	return -1
+}
+
+// compact returns the selected values of a record as a compact row.
+func (r *Reader) compact() [][]byte {
+	for k, i := range r.selection {
+		r.projected[k] = r.rowBuffer[i]
+	}
+	return r.projected
+}
@@ -0,0 +1,113 @@
+package billdsv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+	"github.com/pkg/errors"
+)
+
+func readStrings(t *testing.T, cr *Reader) [][]string {
+	got := [][]string{}
+	err := cr.ReadAll(func(row [][]byte) {
+		values := make([]string, len(row))
+		for i, c := range row {
+			values[i] = string(c)
+		}
+		got = append(got, values)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestReaderSelect(t *testing.T) {
+	cr := NewReader(strings.NewReader(`Id|Notes|Name|Balance
+1|second
+line|Amy|10
+2||Bob|20
+`), 0, DefaultBufferSize)
+	cr.SkipHeading = true
+	cr.Select = []string{"Balance", "Id"}
+
+	assert.Equal(t, [][]string{{"10", "1"}, {"20", "2"}}, readStrings(t, cr))
+	assert.Equal(t, []int{3, 0}, cr.Selected())
+
+	// the values of unselected fields are never copied
+	assert.Equal(t, 0, len(cr.rowBuffer[1]))
+	assert.Equal(t, 0, len(cr.rowBuffer[2]))
+}
+
+func TestReaderSelectIndex(t *testing.T) {
+	cr := NewReader(strings.NewReader(`1|"quoted|value"|a
+2|"multi
+line"|b
+`), 3, DefaultBufferSize)
+	cr.Quote = '"'
+	cr.Select = []string{"2", "0"}
+
+	assert.Equal(t, [][]string{{"a", "1"}, {"b", "2"}}, readStrings(t, cr))
+}
+
+func TestReaderSelectErrors(t *testing.T) {
+	cr := NewReader(strings.NewReader("Id|Name\n1|Amy\n"), 0, DefaultBufferSize)
+	cr.SkipHeading = true
+	cr.Select = []string{"Email"}
+	assert.Equal(t, `no column named "Email" to select`, cr.ReadAll(func([][]byte) {}).Error())
+
+	cr = NewReader(strings.NewReader("1|Amy\n"), 2, DefaultBufferSize)
+	cr.Select = []string{"2"}
+	assert.Equal(t, "can't select column 2 of 2 fields", cr.ReadAll(func([][]byte) {}).Error())
+}
+
+func TestReaderSelectSchema(t *testing.T) {
+	s := carBonusSchema()
+	s.Columns[0].Rules = []Rule{{Min: "1000"}}
+
+	// the amount isn't a decimal, but isn't selected either
+	cr := NewSchemaReader(strings.NewReader(strings.Replace(carBonuses, "|13795|", "|lots|", 1)), s, DefaultBufferSize)
+	cr.SkipHeading = true
+	cr.Select = []string{"CrCBCommitted", "CrCarBonusID"}
+
+	assert.Equal(t, [][]string{{"2015-09-11", "1097684"}, {"2015-11-12", "1155246"}}, readStrings(t, cr))
+	assert.Equal(t, 0, cr.Report().Violations)
+}
+
+func TestReaderSelectRules(t *testing.T) {
+	s := &Schema{Columns: []Column{
+		{Name: "Start", Type: DateType},
+		{Name: "End", Type: DateType, Rules: []Rule{{After: "Start"}}},
+		{Name: "Name", Type: StringType, Rules: []Rule{{Check: func(value []byte, row Row) error {
+			if id, _ := row.String("Id"); id == "" {
+				return errors.New("no id")
+			}
+			return nil
+		}}}},
+		{Name: "Id", Type: StringType, Nullable: true},
+	}}
+	document := "2020-01-01|2019-01-01|Amy|1\n2020-01-01|2021-01-01|Bob|\n"
+
+	cr := NewSchemaReader(strings.NewReader(document), s, DefaultBufferSize)
+	cr.Select = []string{"End", "Name"}
+
+	assert.Equal(t, [][]string{{"2019-01-01", "Amy"}, {"2021-01-01", "Bob"}}, readStrings(t, cr))
+	assert.Equal(t, 2, cr.Report().Violations)
+	assert.Equal(t, 1, cr.Report().Columns[0].Violations)
+	assert.Equal(t, 1, cr.Report().Columns[1].Violations)
+}
+
+func TestProfilerSelect(t *testing.T) {
+	cr := NewReader(strings.NewReader(carBonuses), 0, DefaultBufferSize)
+	cr.SkipHeading = true
+	cr.Select = []string{"CrCBBalance", "CrNumber"}
+
+	p, err := Profiler{}.Profile(cr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(p.Columns))
+	assert.Equal(t, "CrCBBalance", p.Columns[0].Name)
+	assert.Equal(t, "CrNumber", p.Columns[1].Name)
+}
@@ -3,6 +3,7 @@ package billdsv
 import (
 	"bytes"
 	"io"
+	"unicode/utf8"
 
 	"github.com/pkg/errors"
 )
@@ -14,11 +15,132 @@ type Reader struct {
 	SkipHeading bool
 	BufferSize  int
 
+	// Delimiter takes precedence over Separator when set, for separators that
+	// are more than a single byte such as "||", "~|~" or a UTF-8 encoded rune.
+	// Set a rune separator with string(r). Documents with an Encoding are
+	// matched once transcoded, so Delimiter is always UTF-8 while Separator is
+	// the byte in the document's own encoding, such as 0xa6 for "¦" in
+	// Latin-1. Quote and Escape have to be ASCII in such documents.
+	Delimiter string
+
+	// Terminator marks the end of each record and defaults to "\n", in which
+	// case carriage returns are discarded as well. Other terminators such as
+	// the ASCII record separator "\x1e" are matched exactly.
+	Terminator string
+
+	// Quote enables RFC 4180 style quoting when set, usually to '"'. Fields
+	// that begin with the quote may contain separators and line breaks and
+	// represent the quote itself by doubling it. Fields that don't begin with
+	// the quote are read as before, including quotes part way through a value,
+	// and may still span lines using the field count so documents can mix
+	// quoted and unquoted values.
+	Quote byte
+
+	// Escape enables backslash style escapes when set, usually to '\\'. The
+	// escape followed by the separator, the terminator, the quote or another
+	// escape reads as that literal text while "n" and "r" read as a line feed
+	// and carriage return. Unrecognised escapes are kept as they are.
+	Escape byte
+
+	// Preamble is the number of banner lines to discard at the start of the
+	// document, before the heading if there is one.
+	Preamble int
+
+	// Comment is a prefix, such as "#", marking lines to ignore. It's only
+	// checked at the start of a record so a line within a multi-line value is
+	// never mistaken for a comment.
+	Comment string
+
+	// TrailerPrefix marks a trailing line such as a record count. Like
+	// comments it's only checked at the start of a record, the line is kept
+	// out of the records and made available by Trailer instead.
+	TrailerPrefix string
+
+	// Encoding is the character encoding of the document, anything other than
+	// UTF8 is transcoded to UTF-8 before it's parsed.
+	Encoding Encoding
+
+	// TextPolicy sets how values with invalid UTF-8 or control characters are
+	// handled, ColumnPolicies overrides it for individual columns by index.
+	TextPolicy     TextPolicy
+	ColumnPolicies map[int]TextPolicy
+
+	// Schema describes the columns of the document. When set the heading is
+	// checked against the column names and every value is validated before
+	// the record is passed on, see NewSchemaReader.
+	Schema *Schema
+
+	// Select projects records onto the named columns, in the order they're
+	// named, so only their values are copied and ReadAll's function receives a
+	// compact row of them. Columns are named by the schema or heading, or by
+	// their index when no column has the name. The fields that aren't selected
+	// are still counted, so multi-line values are read as before. With a
+	// Schema only the selected columns are transformed, validated and checked
+	// against their rules, the columns those rules compare with being copied
+	// as well.
+	Select []string
+
+	// Filters keep only the records whose values match all of them. Each is
+	// checked as soon as its column has been read, so the rest of a record
+	// that doesn't match is skipped over without being copied and never
+	// reaches ReadAll's function, see FilterStats.
+	Filters []Filter
+
+	// Poison overwrites the values of each record once ReadAll's function has
+	// returned, to catch code that keeps them rather than a copy, see
+	// CopyRow. Kept values then read as 0xde bytes, and reading them on
+	// another goroutine is reported by the race detector. Batcher and Pipeline
+	// poison their buffers as they recycle them too. It's meant for tests and
+	// debugging since it slows reading down.
+	Poison bool
+
+	// StreamDepth is the capacity of the channel of records returned by
+	// Stream, it's unbuffered when zero.
+	StreamDepth int
+
+	// MaxViolations is the number of broken rules after which reading stops
+	// with a *ValidationError, zero to read on whatever the Report holds.
+	MaxViolations int
+
 	r         io.Reader
 	fields    int
+	delim     string
+	term      string
+	crlf      bool
 	rdBuffer  []byte
+	rdIdx     int
+	rdLen     int
+	eof       bool
+	begun     bool
+	atStart   bool
+	trailer   []byte
+	heading   []string
 	wrBuffer  []byte
+	wrIdx     int
+	quoted    bool
+	inQuotes  bool
+	closing   bool
+	escaping  bool
+	field     int
+	rows      int
 	rowBuffer [][]byte
+
+	selection []int
+	selected  []bool
+	copied    []bool
+	projected [][]byte
+	skipping  bool
+	filters   []filter
+	filtering []bool
+	dropping  bool
+
+	sanitizing  bool
+	scratch     []byte
+	diagnostics Diagnostics
+
+	transforms []columnTransform
+	rules      []ruleCheck
+	report     Report
 }
 
 var DefaultBufferSize = 1024
@@ -27,8 +149,10 @@ var DefaultBufferSize = 1024
 // fields per row can be specified so the parser can safely deal with fields
 // containing line breaks. The buffer size may be specified post-instantiate
 // but the default should be fine for most cases. If fields is left at zero, the
-// first line will be used to set the expected field count for the rest of the
-// document. This means that if the CSV is malformed
+// heading (when SkipHeading is set) or a sample of the first few lines will be
+// used to set the expected field count for the rest of the document. This means
+// that if the CSV is malformed the inferred count may be wrong, so prefer
+// declaring it when it's known.
 func NewReader(r io.Reader, fields, bufferSize int) *Reader {
 	return &Reader{
 		Separator:  '|',
@@ -45,109 +169,798 @@ func NewReader(r io.Reader, fields, bufferSize int) *Reader {
 	}
 }
 
+// NewSchemaReader returns a new Reader that reads documents described by s,
+// the number of fields per row being the number of columns in the schema.
+func NewSchemaReader(r io.Reader, s *Schema, bufferSize int) *Reader {
+	cr := NewReader(r, len(s.Columns), bufferSize)
+	cr.Schema = s
+	return cr
+}
+
+// Fields returns the number of fields expected per row. This is zero until the
+// count has been declared, read from the heading or inferred.
+func (r *Reader) Fields() int {
+	return r.fields
+}
+
+// inferLines is the number of complete lines sampled to infer the field count,
+// the read buffer growing up to maxInferBuffer bytes to fit them.
+const (
+	inferLines     = 10
+	maxInferBuffer = 1 << 20
+)
+
+// InferFields determines the number of fields per row for documents without a
+// heading when no count was passed to NewReader. The read buffer is filled and
+// its first lines are sampled, the bytes stay buffered so they are still
+// parsed by ReadAll afterwards. The buffer grows when too few lines fit in it,
+// and an error is returned if not a single line does. ReadAll calls this
+// itself when necessary, it's exported so the count can be inspected before
+// the first record is delivered.
+func (r *Reader) InferFields() (int, error) {
+	if r.fields != 0 {
+		return r.fields, nil
+	}
+
+	if err := r.begin(); err != nil {
+		return 0, err
+	}
+
+	var counts []int
+	for {
+		if err := r.fill(); err != nil {
+			return 0, err
+		}
+		counts = r.separatorCounts(r.rdBuffer[r.rdIdx:r.rdLen])
+		if len(counts) >= inferLines || r.eof || len(r.rdBuffer) >= maxInferBuffer {
+			break
+		}
+		r.rdBuffer = append(r.rdBuffer, make([]byte, len(r.rdBuffer))...)
+	}
+
+	if len(counts) == 0 {
+		if r.rdIdx == r.rdLen {
+			// an empty document has no records whatever the count
+			r.setFields(1)
+			return r.fields, nil
+		}
+		return 0, errors.Errorf("no line fits in %d bytes to infer the number of fields from, declare it instead", len(r.rdBuffer))
+	}
+
+	r.setFields(inferFields(counts))
+	return r.fields, nil
+}
+
+// Heading returns the column names read from the heading when SkipHeading is
+// set, once reading has started.
+func (r *Reader) Heading() []string {
+	return r.heading
+}
+
+// Trailer returns the trailer line, without its terminator, once ReadAll has
+// read it. It's nil if TrailerPrefix isn't set or no trailer was found.
+func (r *Reader) Trailer() []byte {
+	return r.trailer
+}
+
+// separatorCounts counts the separators on up to inferLines complete lines of
+// a sample of the document, ignoring comment and trailer lines.
+func (r *Reader) separatorCounts(sample []byte) []int {
+	var counts []int
+	for len(sample) > 0 && len(counts) < inferLines {
+		end, separators := r.scanLine(sample)
+		if end == -1 {
+			if !r.eof {
+				// the last line was cut off by the end of the buffer
+				break
+			}
+			end = len(sample)
+		}
+
+		line := sample[:end]
+		if !r.isComment(line) && !r.isTrailer(line) {
+			counts = append(counts, separators)
+		}
+
+		if end == len(sample) {
+			break
+		}
+		sample = sample[end+len(r.term):]
+	}
+	return counts
+}
+
+// scanLine finds the terminator ending the first line of a sample and counts
+// the separators before it. Quoted values and escaped text are skipped so the
+// separators and line breaks within them aren't mistaken for the document's.
+// The end is -1 when the sample holds no terminator.
+func (r *Reader) scanLine(sample []byte) (end, separators int) {
+	inQuotes, start := false, true
+	for i := 0; i < len(sample); i++ {
+		c := sample[i]
+		switch {
+		case inQuotes:
+			if c == r.Quote {
+				// a doubled quote stands for the quote itself
+				if i+1 < len(sample) && sample[i+1] == r.Quote {
+					i++
+				} else {
+					inQuotes = false
+				}
+			}
+		case r.Escape != 0 && c == r.Escape:
+			i += r.escaped(sample[i+1:])
+		case r.Quote != 0 && c == r.Quote && start:
+			inQuotes = true
+		case bytes.HasPrefix(sample[i:], []byte(r.delim)):
+			separators++
+			i += len(r.delim) - 1
+			start = true
+			continue
+		case bytes.HasPrefix(sample[i:], []byte(r.term)):
+			return i, separators
+		}
+		start = false
+	}
+	return -1, separators
+}
+
+// escaped returns the length of the text following an escape that it applies
+// to, the separator or terminator if it begins with either.
+func (r *Reader) escaped(text []byte) int {
+	n := 0
+	if r.crlf && len(text) > 0 && text[0] == '\r' {
+		// the escaped terminator is the line feed that follows
+		n, text = 1, text[1:]
+	}
+
+	switch {
+	case bytes.HasPrefix(text, []byte(r.delim)):
+		return n + len(r.delim)
+	case bytes.HasPrefix(text, []byte(r.term)):
+		return n + len(r.term)
+	}
+	return 1
+}
+
+// inferFields guesses the field count from the separator counts of a sample
+// of lines. Each count seen is tried in turn, the lines being joined into rows
+// of that many separators as the fragments of multi-line values would be, and
+// the count leaving the fewest lines that don't fit wins. Ties favour the
+// larger count since a line can't hold more separators than a whole row.
+func inferFields(counts []int) int {
+	separators, best := 0, -1
+	for i, n := range counts {
+		if tried(counts[:i], n) {
+			continue
+		}
+
+		misfits, joined := 0, 0
+		for _, c := range counts {
+			joined += c
+			if joined == n {
+				joined = 0
+			} else if joined > n {
+				misfits++
+				joined = 0
+			}
+		}
+
+		if best == -1 || misfits < best || (misfits == best && n > separators) {
+			separators, best = n, misfits
+		}
+	}
+	return separators + 1
+}
+
+// tried reports whether n is one of the counts already tried.
+func tried(counts []int, n int) bool {
+	for _, c := range counts {
+		if c == n {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Reader) isComment(line []byte) bool {
+	return r.Comment != "" && bytes.HasPrefix(line, []byte(r.Comment))
+}
+
+func (r *Reader) isTrailer(line []byte) bool {
+	return r.TrailerPrefix != "" && bytes.HasPrefix(line, []byte(r.TrailerPrefix))
+}
+
+// begin resolves the reader's configuration and discards the preamble, it only
+// has an effect the first time it's called.
+func (r *Reader) begin() error {
+	if r.begun {
+		return nil
+	}
+	r.begun = true
+	r.atStart = true
+
+	if r.Encoding != UTF8 {
+		r.r = newDecoder(r.r, r.Encoding)
+	}
+	if err := r.prepare(); err != nil {
+		return err
+	}
+
+	if r.Schema != nil {
+		var err error
+		if r.transforms, r.rules, r.report, err = r.Schema.compileRules(); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < r.Preamble; i++ {
+		if err := r.line(false); err != nil {
+			return err
+		}
+		r.rdIdx++
+	}
+
+	return nil
+}
+
+// line reads up to the end of the current line, appending it to the trailer
+// if keep is set or otherwise discarding it. Like consume the read position is
+// left on the final byte of the terminator.
+func (r *Reader) line(keep bool) error {
+	for {
+		if err := r.fill(); err != nil {
+			return err
+		}
+
+		unread := r.rdBuffer[r.rdIdx:r.rdLen]
+		end := bytes.Index(unread, []byte(r.term))
+		if end == -1 && !r.eof {
+			// hold on to anything that could be the start of the terminator
+			end = len(unread) - (len(r.term) - 1)
+		}
+
+		if end == -1 {
+			end = len(unread)
+		}
+		if keep {
+			for _, c := range unread[:end] {
+				if c != '\r' || !r.crlf {
+					r.trailer = append(r.trailer, c)
+				}
+			}
+		}
+
+		if end < len(unread) && bytes.HasPrefix(unread[end:], []byte(r.term)) {
+			r.rdIdx += end + len(r.term) - 1
+			return nil
+		}
+		if r.eof {
+			r.rdIdx = r.rdLen - 1
+			return nil
+		}
+		r.rdIdx += end
+	}
+}
+
+// prepare resolves the configured separator and terminator into the tokens
+// matched by the parser, which are matched once the document is transcoded.
+func (r *Reader) prepare() error {
+	r.delim = r.Delimiter
+	if r.delim == "" {
+		r.delim = string([]byte{r.Separator})
+		if r.Encoding != UTF8 && r.Separator >= utf8.RuneSelf {
+			c, ok := r.Encoding.decodeByte(r.Separator)
+			if !ok {
+				return errors.Errorf("separator 0x%02x isn't a character in the document's encoding, set Delimiter instead", r.Separator)
+			}
+			r.delim = string(c)
+		}
+	}
+	if r.Encoding != UTF8 && (r.Quote >= utf8.RuneSelf || r.Escape >= utf8.RuneSelf) {
+		return errors.New("the quote and escape must be ASCII in documents with an encoding")
+	}
+
+	r.term = r.Terminator
+	if r.term == "" {
+		r.term = "\n"
+	}
+	r.crlf = r.term == "\n"
+
+	r.sanitizing = r.TextPolicy != PassThrough
+	for _, p := range r.ColumnPolicies {
+		r.sanitizing = r.sanitizing || p != PassThrough
+	}
+	return nil
+}
+
+func (r *Reader) setFields(fields int) {
+	// since the field count was calculated at "runtime" it needs to allocate
+	// the row buffer because the NewReader function would have allocated it
+	// with 0
+	r.fields = fields
+	r.rowBuffer = make([][]byte, fields)
+}
+
+// read makes sure there's unread data in the read buffer, reading another
+// chunk from the underlying reader once the current one has been consumed. It
+// returns false once the underlying reader is exhausted.
+func (r *Reader) read() (bool, error) {
+	for r.rdIdx >= r.rdLen {
+		if r.eof {
+			return false, nil
+		}
+
+		n, err := r.r.Read(r.rdBuffer)
+		if err == io.EOF {
+			r.eof = true
+		} else if err != nil {
+			return false, err
+		}
+		r.rdIdx, r.rdLen = 0, n
+	}
+
+	return true, nil
+}
+
+// fill shifts any unread bytes to the front of the read buffer and reads from
+// the underlying reader until the buffer is full or the reader is exhausted.
+func (r *Reader) fill() error {
+	r.rdLen = copy(r.rdBuffer, r.rdBuffer[r.rdIdx:r.rdLen])
+	r.rdIdx = 0
+
+	for r.rdLen < len(r.rdBuffer) && !r.eof {
+		n, err := r.r.Read(r.rdBuffer[r.rdLen:])
+		r.rdLen += n
+		if err == io.EOF {
+			r.eof = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// skipHeading consumes the heading line, counting the field headings and using
+// the count if necessary.
+func (r *Reader) skipHeading() error {
+	if err := r.begin(); err != nil {
+		return err
+	}
+	if err := r.fill(); err != nil {
+		return err
+	}
+
+	end := bytes.Index(r.rdBuffer[:r.rdLen], []byte(r.term))
+	if end == -1 {
+		if !r.eof {
+			return errors.New("heading doesn't fit in the read buffer")
+		}
+		end = r.rdLen
+	}
+
+	line := r.rdBuffer[:end]
+	if r.crlf {
+		line = bytes.TrimRight(line, "\r")
+	}
+
+	r.heading = r.heading[:0]
+	for _, name := range bytes.Split(line, []byte(r.delim)) {
+		if r.Quote != 0 && len(name) > 1 && name[0] == r.Quote && name[len(name)-1] == r.Quote {
+			name = name[1 : len(name)-1]
+		}
+		r.heading = append(r.heading, string(name))
+	}
+
+	if r.fields == 0 {
+		r.setFields(len(r.heading))
+	} else if len(r.heading) != r.fields {
+		return errors.New("declared fields does not match headings")
+	}
+
+	r.rdIdx = end + len(r.term)
+	if r.rdIdx > r.rdLen {
+		r.rdIdx = r.rdLen
+	}
+	return nil
+}
+
+// consume reports whether tok begins at the current read position, the caller
+// having already compared the first byte. Longer tokens may straddle the end of
+// the read buffer so it's refilled in order to look ahead. When tok matches the
+// read position is left on its final byte.
+func (r *Reader) consume(tok string) (bool, error) {
+	if len(tok) == 1 {
+		return true, nil
+	}
+
+	ok, err := r.peek(tok)
+	if ok {
+		r.rdIdx += len(tok) - 1
+	}
+	return ok, err
+}
+
 // ReadAll reads all records and passes them to the specified function. This
 // function will make no heap allocations in best case scenarios. The only time
 // this function will allocate is if a field exceeds the default field buffer
 // size of 1024, in which case the struct field `wrBuffer` will be resized to
-// 1.5x the size. The other two potential allocation spots, are the two `append`
-// calls in the switch blocks, these are allocated lazily as well as if the
-// `rowBuffer` cell is at capacity and requires resizing to fit the new data.
-func (r *Reader) ReadAll(function func([][]byte)) (err error) {
+// 1.5x the size. The other potential allocation spot is the `append` in
+// `commit`, these are allocated lazily as well as if the `rowBuffer` cell is at
+// capacity and requires resizing to fit the new data.
+func (r *Reader) ReadAll(function func([][]byte)) error {
+	return r.readAll(func(row [][]byte) error {
+		function(row)
+		return nil
+	})
+}
+
+// errStop is returned by a readAll function to stop reading without error.
+var errStop = errors.New("stop reading")
+
+// readAll implements ReadAll for functions that may stop reading early by
+// returning an error, which is returned by readAll unless it's errStop.
+func (r *Reader) readAll(function func([][]byte) error) (err error) {
+	defer func() {
+		if err == errStop {
+			err = nil
+		}
+	}()
+
+	if err = r.begin(); err != nil {
+		return err
+	}
+
+	if r.SkipHeading {
+		if err = r.skipHeading(); err != nil {
+			return err
+		}
+		r.rows = 1
+
+		if r.Schema != nil {
+			if err = r.Schema.CheckHeading(r.heading); err != nil {
+				return err
+			}
+		}
+	} else if _, err = r.InferFields(); err != nil {
+		return err
+	}
+
 	// the buffer size must be able to accommodate at least `n` fields as well as
 	// `n-1` field separators.
 	if r.fields > r.BufferSize/2 {
 		return errors.New("buffer size isn't large enough for the amount of specified fields")
 	}
+	if len(r.delim) > len(r.rdBuffer) || len(r.term) > len(r.rdBuffer) {
+		return errors.New("buffer size isn't large enough for the separator or terminator")
+	}
+	if err = r.project(); err != nil {
+		return err
+	}
+	if err = r.compileFilters(); err != nil {
+		return err
+	}
+	r.skipping = r.skips(0)
 
-	var (
-		rdBufferLen int
-		rdIdx       int
-		wrIdx       int
-		fields      int
-		rows        int
-	)
+	var ok bool
 
 	for {
-		if rdBufferLen, err = r.r.Read(r.rdBuffer); err == io.EOF {
-			if rdBufferLen == 0 {
-				break
-			}
-		} else if err != nil {
+		if ok, err = r.read(); err != nil {
 			return err
+		} else if !ok {
+			break
 		}
-		rdIdx = 0
-
-		if rows == 0 && r.SkipHeading {
-			// consume the heading bytes and discard
-			// counting the field headings and using the count if necessary
-			for ; rdIdx < rdBufferLen; rdIdx++ {
-				if r.rdBuffer[rdIdx] == '\n' {
-					headings := len(bytes.Split(r.rdBuffer[:rdIdx], []byte{r.Separator}))
-					if r.fields == 0 {
-						// since the field count was calculated at "runtime"
-						// it needs to allocate the row buffer because the
-						// NewReader function would have allocated it with 0
-						r.fields = headings
-						r.rowBuffer = make([][]byte, headings)
-					} else {
-						if headings != r.fields {
-							return errors.New("declared fields does not match headings")
-						}
-					}
-					rows = 1
-					rdIdx++
-					break
+
+		for ; r.rdIdx < r.rdLen; r.rdIdx++ {
+			c := r.rdBuffer[r.rdIdx]
+
+			if r.atStart {
+				if ok, err = r.skipLine(c); err != nil {
+					return err
+				} else if ok {
+					continue
 				}
 			}
-		}
 
-		for ; rdIdx < rdBufferLen; rdIdx++ {
-			switch r.rdBuffer[rdIdx] {
-			case r.Separator:
-				if fields >= len(r.rowBuffer) {
-					return errors.Errorf("on row %d, expected %d fields but read an extra field", rows, len(r.rowBuffer))
+			if r.Escape != 0 {
+				if ok, err = r.unescape(c); err != nil {
+					return err
+				} else if ok {
+					continue
 				}
-				r.rowBuffer[fields] = r.rowBuffer[fields][:0]
-				r.rowBuffer[fields] = append(r.rowBuffer[fields], r.wrBuffer...)
-				r.rowBuffer[fields] = r.rowBuffer[fields][0:wrIdx]
-				wrIdx = 0
-				fields++
-
-			case '\r':
-				continue
+			}
 
-			case '\n':
-				if fields == r.fields-1 {
-					r.rowBuffer[fields] = r.rowBuffer[fields][:0]
-					r.rowBuffer[fields] = append(r.rowBuffer[fields], r.wrBuffer...)
-					r.rowBuffer[fields] = r.rowBuffer[fields][0:wrIdx]
-					wrIdx = 0
-					fields = 0
+			if r.Quote != 0 {
+				if ok, err = r.unquote(c); err != nil {
+					return err
+				} else if ok {
+					continue
+				}
+			}
 
-					function(r.rowBuffer)
-					rows++
+			if c == r.delim[0] {
+				if ok, err = r.consume(r.delim); err != nil {
+					return err
+				} else if ok {
+					if r.field >= len(r.rowBuffer) {
+						return errors.Errorf("on row %d, expected %d fields but read an extra field", r.rows, len(r.rowBuffer))
+					}
+					r.commit()
+					r.field++
+					r.skipping = r.skips(r.field)
 					continue
 				}
+			}
 
-				fallthrough
+			if c == r.term[0] {
+				if ok, err = r.consume(r.term); err != nil {
+					return err
+				} else if ok {
+					if r.field == r.fields-1 {
+						r.commit()
+						r.field = 0
 
-			default:
-				if wrIdx >= len(r.wrBuffer) {
-					r.wrBuffer = append(r.wrBuffer, make([]byte, int(float64(len(r.wrBuffer))*1.5))...)
+						if err = r.deliver(function); err != nil {
+							return err
+						}
+						r.rows++
+						r.atStart = true
+						r.skipping = r.skips(0)
+						continue
+					}
+
+					if r.quoted {
+						return errors.Errorf("on row %d, expected %d fields but the row ended after a quoted field", r.rows, r.fields)
+					}
+
+					// not enough fields have been read for the row to be
+					// complete so the terminator is part of a multi-line value
+					r.stage(r.term)
+					continue
 				}
-				r.wrBuffer[wrIdx] = r.rdBuffer[rdIdx]
-				wrIdx++
 			}
+
+			if c == '\r' && r.crlf {
+				continue
+			}
+
+			if r.quoted {
+				return errors.Errorf("on row %d, unexpected data after quoted field %d", r.rows, r.field)
+			}
+
+			r.stageByte(c)
 		}
 	}
 
-	if (wrIdx != 0 && r.wrBuffer != nil) || (fields == r.fields-1 && wrIdx == 0) {
-		r.rowBuffer[fields] = r.rowBuffer[fields][:0]
-		r.rowBuffer[fields] = append(r.rowBuffer[fields], r.wrBuffer...)
-		r.rowBuffer[fields] = r.rowBuffer[fields][0:wrIdx]
+	if r.inQuotes {
+		return errors.Errorf("on row %d, quoted field %d isn't closed", r.rows, r.field)
+	}
+	if r.escaping {
+		r.stageByte(r.Escape)
+		r.escaping = false
+	}
 
-		function(r.rowBuffer)
+	if r.wrIdx != 0 || r.quoted || (r.field != 0 && r.field == r.fields-1) {
+		if r.field >= len(r.rowBuffer) {
+			return errors.Errorf("on row %d, expected %d fields but read an extra field", r.rows, len(r.rowBuffer))
+		}
+		if r.field != r.fields-1 {
+			// the document was cut off part way through the record
+			return errors.Errorf("on row %d, expected %d fields but the document ended after %d", r.rows, r.fields, r.field+1)
+		}
+		r.commit()
+
+		if err = r.deliver(function); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
+
+// deliver passes a completed row to the caller's function.
+func (r *Reader) deliver(function func([][]byte) error) error {
+	r.diagnostics.Records++
+
+	if r.dropping {
+		r.dropping = false
+		r.diagnostics.Filtered++
+		return nil
+	}
+
+	if r.sanitizing && !r.sanitize() {
+		r.diagnostics.Rejected++
+		return nil
+	}
+
+	if r.Schema != nil {
+		r.report.Records++
+		if len(r.transforms) != 0 {
+			if err := r.transform(); err != nil {
+				return err
+			}
+		}
+		if err := r.Schema.validate(r.diagnostics.Records, r.rowBuffer, r.selected); err != nil {
+			return err
+		}
+		if len(r.rules) != 0 {
+			if err := r.checkRules(); err != nil {
+				return err
+			}
+		}
+	}
+
+	row := r.rowBuffer
+	if r.selection != nil {
+		row = r.compact()
+	}
+	err := function(row)
+
+	if r.Poison {
+		for _, value := range r.rowBuffer {
+			poison(value)
+		}
+	}
+	return err
+}
+
+// skipLine checks for comment and trailer lines at the start of a record,
+// reporting whether the line was consumed.
+func (r *Reader) skipLine(c byte) (ok bool, err error) {
+	if c == '\r' && r.crlf {
+		return false, nil
+	}
+	r.atStart = false
+
+	if r.Comment != "" && c == r.Comment[0] {
+		if ok, err = r.peek(r.Comment); err != nil || !ok {
+			return false, err
+		}
+		r.atStart = true
+		return true, r.line(false)
+	}
+
+	if r.TrailerPrefix != "" && c == r.TrailerPrefix[0] {
+		if ok, err = r.peek(r.TrailerPrefix); err != nil || !ok {
+			return false, err
+		}
+		r.atStart = true
+		r.trailer = r.trailer[:0]
+		return true, r.line(true)
+	}
+
+	return false, nil
+}
+
+// peek reports whether tok begins at the current read position without moving
+// it, refilling the read buffer to look ahead if necessary.
+func (r *Reader) peek(tok string) (bool, error) {
+	if r.rdLen-r.rdIdx < len(tok) && !r.eof {
+		if err := r.fill(); err != nil {
+			return false, err
+		}
+	}
+
+	return r.rdLen-r.rdIdx >= len(tok) && string(r.rdBuffer[r.rdIdx:r.rdIdx+len(tok)]) == tok, nil
+}
+
+// unescape handles the escape character when escapes are enabled, reporting
+// whether c was consumed.
+func (r *Reader) unescape(c byte) (bool, error) {
+	if !r.escaping {
+		if c == r.Escape {
+			r.escaping = true
+			return true, nil
+		}
+		return false, nil
+	}
+	r.escaping = false
+
+	if r.Quote != 0 && c == r.Quote {
+		// an escaped quote never opens or closes a quoted value
+		r.stageByte(c)
+		return true, nil
+	}
+
+	switch c {
+	case r.Escape:
+		r.stageByte(c)
+	case 'n':
+		r.stageByte('\n')
+	case 'r':
+		r.stageByte('\r')
+	default:
+		if c == '\r' && r.crlf {
+			// the escaped terminator is the line feed that follows
+			r.escaping = true
+			return true, nil
+		}
+
+		for _, tok := range [...]string{r.delim, r.term} {
+			if c != tok[0] {
+				continue
+			}
+			if ok, err := r.consume(tok); err != nil {
+				return false, err
+			} else if ok {
+				r.stage(tok)
+				return true, nil
+			}
+		}
+
+		r.stageByte(r.Escape)
+		r.stageByte(c)
+	}
+
+	return true, nil
+}
+
+// unquote handles the quote character when quoting is enabled, reporting
+// whether c was consumed. Within a quoted field everything other than the quote
+// is staged as is, a quote either closes the field or, when immediately followed
+// by another, stands for a single literal quote.
+func (r *Reader) unquote(c byte) (bool, error) {
+	if r.inQuotes {
+		if c == r.Quote {
+			r.inQuotes = false
+			r.closing = true
+		} else if c != '\r' || !r.crlf {
+			r.stageByte(c)
+		}
+		return true, nil
+	}
+
+	if r.closing {
+		r.closing = false
+		if c == r.Quote {
+			r.stageByte(c)
+			r.inQuotes = true
+			return true, nil
+		}
+		return false, nil
+	}
+
+	if c == r.Quote && r.wrIdx == 0 && !r.quoted {
+		r.inQuotes = true
+		r.quoted = true
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// stageByte appends a byte to the field currently being read.
+func (r *Reader) stageByte(c byte) {
+	if r.skipping {
+		// the field isn't selected so only its length is kept
+		r.wrIdx++
+		return
+	}
+	if r.wrIdx >= len(r.wrBuffer) {
+		r.wrBuffer = append(r.wrBuffer, make([]byte, int(float64(len(r.wrBuffer))*1.5))...)
+	}
+	r.wrBuffer[r.wrIdx] = c
+	r.wrIdx++
+}
+
+// stage appends a token to the field currently being read.
+func (r *Reader) stage(tok string) {
+	for i := 0; i < len(tok); i++ {
+		r.stageByte(tok[i])
+	}
+}
+
+// commit copies the staged field data into the row buffer, unless the field
+// isn't selected or the record is filtered out.
+func (r *Reader) commit() {
+	if !r.skipping {
+		if r.filtering != nil && r.filtering[r.field] && !r.filter(r.wrBuffer[:r.wrIdx]) {
+			r.dropping = true
+		} else if r.copied == nil || r.copied[r.field] {
+			r.rowBuffer[r.field] = append(r.rowBuffer[r.field][:0], r.wrBuffer[:r.wrIdx]...)
+		}
+	}
+	r.wrIdx = 0
+	r.quoted = false
+}
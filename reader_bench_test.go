@@ -24,6 +24,22 @@ func BenchmarkReader(b *testing.B) {
 	}
 }
 
+func BenchmarkReaderSelect(b *testing.B) {
+	r := make([]*Reader, b.N)
+	for i := 0; i < b.N; i++ {
+		r[i] = NewReader(generateCSV(1000000, 134), 134, DefaultBufferSize)
+		r[i].Select = []string{"0", "12", "40", "99", "133"}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r[i].ReadAll(func(row [][]byte) {
+			e = row
+		})
+	}
+}
+
 // returns a reader that produces a CSV of the specified rows and columns filled
 // with placeholder data.
 func generateCSV(rows int, cols int) (r io.Reader) {
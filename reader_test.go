@@ -467,6 +467,346 @@ func TestReader10(t *testing.T) {
 	assert.Equal(t, want, got)
 }
 
+func TestReaderInferFields(t *testing.T) {
+	f := strings.NewReader(`1000|first string|final string
+1001|second string
+that is
+multi-line|final string
+1002|third string|final string
+`)
+
+	want := [][]string{
+		{"1000", "first string", "final string"},
+		{"1001", "second string\nthat is\nmulti-line", "final string"},
+		{"1002", "third string", "final string"},
+	}
+
+	got := [][]string{}
+
+	cr := NewReader(f, 0, DefaultBufferSize)
+
+	fields, err := cr.InferFields()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, fields)
+
+	err = cr.ReadAll(func(row [][]byte) {
+		fmt.Println(truncateStrings(20, row))
+		rowStrings := make([]string, 3)
+		for i, c := range row {
+			rowStrings[i] = string(c)
+		}
+		got = append(got, rowStrings)
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, want, got)
+}
+
+func TestReaderInferFieldsSingle(t *testing.T) {
+	f := strings.NewReader("first\nsecond\nthird")
+
+	want := [][]string{{"first"}, {"second"}, {"third"}}
+
+	got := [][]string{}
+
+	cr := NewReader(f, 0, DefaultBufferSize)
+
+	err := cr.ReadAll(func(row [][]byte) {
+		got = append(got, []string{string(row[0])})
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, 1, cr.Fields())
+	assert.Equal(t, want, got)
+}
+
+func TestReaderInferFieldsLongLines(t *testing.T) {
+	// every line is longer than the read buffer
+	cr := NewReader(generateCSV(5, 134), 0, DefaultBufferSize)
+
+	rows := 0
+	err := cr.ReadAll(func(row [][]byte) {
+		assert.Equal(t, "field 133", string(row[133]))
+		rows++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 134, cr.Fields())
+	assert.Equal(t, 5, rows)
+}
+
+func TestReaderInferFieldsFragments(t *testing.T) {
+	// the fragments either side of the line breaks outnumber the whole rows
+	f := strings.NewReader("a|b|c\nd|e\nf|g\nh|i\nj|k\nl|m|n\n")
+
+	cr := NewReader(f, 0, DefaultBufferSize)
+	fields, err := cr.InferFields()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, fields)
+}
+
+func TestReaderInferFieldsLargeBuffer(t *testing.T) {
+	// only the first few lines are sampled however many fit in the buffer
+	f := strings.NewReader(strings.Repeat("1|2\n", 1<<18))
+
+	cr := NewReader(f, 0, 1<<20)
+	fields, err := cr.InferFields()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, fields)
+}
+
+func TestReaderInferFieldsNoLine(t *testing.T) {
+	f := strings.NewReader(strings.Repeat("x|", 1<<20))
+
+	cr := NewReader(f, 0, DefaultBufferSize)
+	_, err := cr.InferFields()
+	assert.Equal(t, "no line fits in 1048576 bytes to infer the number of fields from, declare it instead", err.Error())
+}
+
+func TestReaderDelimiter(t *testing.T) {
+	for _, delim := range []string{"||", "~|~", "¦"} {
+		f := strings.NewReader(strings.Replace(`1000{}first string{}final string
+1001{}second string
+that is multi-line{}final | string
+1002{}third string{}final string
+`, "{}", delim, -1))
+
+		want := [][]string{
+			{"1000", "first string", "final string"},
+			{"1001", "second string\nthat is multi-line", "final | string"},
+			{"1002", "third string", "final string"},
+		}
+
+		got := [][]string{}
+
+		// a small buffer makes sure delimiters straddle buffer refills
+		cr := NewReader(f, 3, 7)
+		cr.Delimiter = delim
+
+		err := cr.ReadAll(func(row [][]byte) {
+			rowStrings := make([]string, 3)
+			for i, c := range row {
+				rowStrings[i] = string(c)
+			}
+			got = append(got, rowStrings)
+		})
+		if err != nil {
+			t.Error(err)
+		}
+
+		assert.Equal(t, want, got)
+	}
+}
+
+func TestReaderTerminator(t *testing.T) {
+	f := strings.NewReader("A\x1fB\x1fC\x1e1000\x1ffirst\nstring\x1ffinal string\x1e1001\x1fsecond string\x1ffinal\r\nstring\x1e")
+
+	want := [][]string{
+		{"1000", "first\nstring", "final string"},
+		{"1001", "second string", "final\r\nstring"},
+	}
+
+	got := [][]string{}
+
+	cr := NewReader(f, 0, DefaultBufferSize)
+	cr.Separator = 0x1f
+	cr.Terminator = "\x1e"
+	cr.SkipHeading = true
+
+	err := cr.ReadAll(func(row [][]byte) {
+		rowStrings := make([]string, 3)
+		for i, c := range row {
+			rowStrings[i] = string(c)
+		}
+		got = append(got, rowStrings)
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, 3, cr.Fields())
+	assert.Equal(t, want, got)
+}
+
+func TestReaderQuote(t *testing.T) {
+	f := strings.NewReader(`A|B|C
+1000|"first|string"|final string
+1001|second string
+that is multi-line|"final
+""quoted"" string"
+1002|""|str"3
+"1003"|fourth string|
+`)
+
+	want := [][]string{
+		{"1000", "first|string", "final string"},
+		{"1001", "second string\nthat is multi-line", "final\n\"quoted\" string"},
+		{"1002", "", "str\"3"},
+		{"1003", "fourth string", ""},
+	}
+
+	got := [][]string{}
+
+	cr := NewReader(f, 3, DefaultBufferSize)
+	cr.Quote = '"'
+	cr.SkipHeading = true
+
+	err := cr.ReadAll(func(row [][]byte) {
+		rowStrings := make([]string, 3)
+		for i, c := range row {
+			rowStrings[i] = string(c)
+		}
+		got = append(got, rowStrings)
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, want, got)
+}
+
+func TestReaderQuoteInferFields(t *testing.T) {
+	// the separators and line breaks in quoted values aren't counted
+	f := strings.NewReader("1|\"a|b\"|c\n2|\"d\ne|f\"|\"\"\"g|\"\n")
+
+	cr := NewReader(f, 0, DefaultBufferSize)
+	cr.Quote = '"'
+
+	assert.Equal(t, [][]string{{"1", "a|b", "c"}, {"2", "d\ne|f", "\"g|"}}, readStrings(t, cr))
+	assert.Equal(t, 3, cr.Fields())
+}
+
+func TestReaderQuoteErrors(t *testing.T) {
+	for _, in := range []string{
+		"1000|\"first\"string|final string\n",
+		"1000|\"first string|final string\n",
+		"1000|\"first string\"\nfinal string\n",
+	} {
+		cr := NewReader(strings.NewReader(in), 3, DefaultBufferSize)
+		cr.Quote = '"'
+
+		err := cr.ReadAll(func(row [][]byte) {})
+		assert.NotEqual(t, nil, err)
+	}
+}
+
+func TestReaderEscapeInferFields(t *testing.T) {
+	// escaped separators and line breaks aren't counted
+	f := strings.NewReader("1|a\\|b|c\r\n2|d\\\r\ne|f\\\\\r\n")
+
+	cr := NewReader(f, 0, DefaultBufferSize)
+	cr.Escape = '\\'
+
+	assert.Equal(t, [][]string{{"1", "a|b", "c"}, {"2", "d\ne", "f\\"}}, readStrings(t, cr))
+	assert.Equal(t, 3, cr.Fields())
+}
+
+func TestReaderEscape(t *testing.T) {
+	f := strings.NewReader(`1000|first\|string|final string
+1001|second\nstring\\|final \
+string
+1002|third string|final\qstring
+`)
+
+	want := [][]string{
+		{"1000", "first|string", "final string"},
+		{"1001", "second\nstring\\", "final \nstring"},
+		{"1002", "third string", "final\\qstring"},
+	}
+
+	got := [][]string{}
+
+	cr := NewReader(f, 3, DefaultBufferSize)
+	cr.Escape = '\\'
+
+	err := cr.ReadAll(func(row [][]byte) {
+		rowStrings := make([]string, 3)
+		for i, c := range row {
+			rowStrings[i] = string(c)
+		}
+		got = append(got, rowStrings)
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, want, got)
+}
+
+func TestReaderPreambleCommentsTrailer(t *testing.T) {
+	f := strings.NewReader(`Bill extract
+Generated 2018-07-31
+A|B|C
+# comment
+1000|first string|final string
+1001|second string
+# that is multi-line|final string
+# another comment
+1002|third string|final string
+TRL|3
+`)
+
+	want := [][]string{
+		{"1000", "first string", "final string"},
+		{"1001", "second string\n# that is multi-line", "final string"},
+		{"1002", "third string", "final string"},
+	}
+
+	got := [][]string{}
+
+	cr := NewReader(f, 0, DefaultBufferSize)
+	cr.SkipHeading = true
+	cr.Preamble = 2
+	cr.Comment = "#"
+	cr.TrailerPrefix = "TRL|"
+
+	err := cr.ReadAll(func(row [][]byte) {
+		rowStrings := make([]string, 3)
+		for i, c := range row {
+			rowStrings[i] = string(c)
+		}
+		got = append(got, rowStrings)
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, want, got)
+	assert.Equal(t, "TRL|3", string(cr.Trailer()))
+}
+
+func TestReaderInferFieldsComments(t *testing.T) {
+	f := strings.NewReader("# a|comment|with|separators\n# and|another\n1000|first|final\n1001|second|final\nTRL|2")
+
+	cr := NewReader(f, 0, DefaultBufferSize)
+	cr.Comment = "#"
+	cr.TrailerPrefix = "TRL"
+
+	rows := 0
+	err := cr.ReadAll(func(row [][]byte) {
+		rows++
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, 3, cr.Fields())
+	assert.Equal(t, 2, rows)
+	assert.Equal(t, "TRL|2", string(cr.Trailer()))
+}
+
 func truncateStrings(limit int, in [][]byte) string {
 	sb := strings.Builder{}
 	sb.WriteString("[")
@@ -0,0 +1,236 @@
+package billdsv
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Control holds the totals a drop is expected to add up to, as stated by its
+// trailer line or a sidecar control file.
+type Control struct {
+	// Records is the expected number of records, -1 if it isn't stated.
+	Records int
+
+	// Sums maps a column index to the expected total of that column.
+	Sums map[int]*big.Rat
+}
+
+// ControlFormat describes the layout of a control line, for example
+// "TRL|1234|-150.00" would be described by a Records index of 1 and a Sums
+// entry mapping field 2 of the control line to the amount column.
+type ControlFormat struct {
+	// Separator splits the control line into fields, it defaults to "|".
+	Separator string
+
+	// Records is the index of the field holding the record count. Zero means
+	// the control line doesn't include one, unless HasRecords is set for a
+	// count in the first field.
+	Records    int
+	HasRecords bool
+
+	// Sums maps the index of a field in the control line to the index of the
+	// column it's the total of.
+	Sums map[int]int
+}
+
+// Parse parses a control line.
+func (f ControlFormat) Parse(line []byte) (*Control, error) {
+	sep := f.Separator
+	if sep == "" {
+		sep = "|"
+	}
+	fields := bytes.Split(bytes.TrimRight(line, "\r\n"), []byte(sep))
+
+	control := &Control{Records: -1, Sums: map[int]*big.Rat{}}
+
+	if f.Records > 0 || f.HasRecords {
+		if f.Records >= len(fields) {
+			return nil, errors.Errorf("control line has %d fields, the record count should be field %d", len(fields), f.Records)
+		}
+		n, err := strconv.Atoi(string(bytes.TrimSpace(fields[f.Records])))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse the control record count")
+		}
+		control.Records = n
+	}
+
+	for field, column := range f.Sums {
+		if field >= len(fields) {
+			return nil, errors.Errorf("control line has %d fields, the total of column %d should be field %d", len(fields), column, field)
+		}
+		sum, err := ParseDecimal(fields[field])
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse the control total of column %d", column)
+		}
+		control.Sums[column] = sum.Rat()
+	}
+
+	return control, nil
+}
+
+// ParseFile parses a sidecar control file, the first non-empty line is taken
+// as the control line.
+func (f ControlFormat) ParseFile(r io.Reader) (*Control, error) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		if len(bytes.TrimSpace(s.Bytes())) != 0 {
+			return f.Parse(s.Bytes())
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("control file is empty")
+}
+
+// Mismatch is a control total that doesn't agree with what was read.
+type Mismatch struct {
+	Name     string
+	Expected string
+	Actual   string
+}
+
+// ReconciliationError is returned when the records read don't agree with the
+// control totals, usually because a drop was truncated or only partially
+// written.
+type ReconciliationError struct {
+	Mismatches []Mismatch
+}
+
+func (e *ReconciliationError) Error() string {
+	sb := strings.Builder{}
+	sb.WriteString("records don't reconcile with control totals:")
+	for _, m := range e.Mismatches {
+		sb.WriteString(fmt.Sprintf(" %s expected %s but read %s;", m.Name, m.Expected, m.Actual))
+	}
+	return strings.TrimSuffix(sb.String(), ";")
+}
+
+// Reconciler reads records while keeping a count and the totals of amount
+// columns, comparing them to the control totals once reading completes.
+// Amounts and control totals are parsed with ParseDecimal and summed exactly.
+type Reconciler struct {
+	Format ControlFormat
+
+	// Control holds the expected totals when they come from a sidecar file,
+	// when nil they're parsed from the reader's trailer line using Format.
+	Control *Control
+}
+
+// ReadAll reads all records from r, passing them to function, and then
+// reconciles them with the control totals. A *ReconciliationError is returned
+// if they don't agree. Records are passed on as they're read so a caller
+// loading them should only commit once ReadAll has returned without error.
+//
+// The record count includes the records the reader's Filters or a Reject
+// policy drop, but since their amounts never reach the reconciler totals
+// can't be reconciled along with either.
+func (c *Reconciler) ReadAll(r *Reader, function func([][]byte)) error {
+	var (
+		sums    = map[int]*big.Rat{}
+		columns []int
+		err     error
+	)
+
+	if c.Control != nil {
+		for column := range c.Control.Sums {
+			columns = append(columns, column)
+		}
+	} else {
+		for _, column := range c.Format.Sums {
+			columns = append(columns, column)
+		}
+	}
+	sort.Ints(columns)
+	for _, column := range columns {
+		sums[column] = new(big.Rat)
+	}
+
+	if len(columns) > 0 && r.drops() {
+		return errors.New("totals can't be reconciled while records are filtered or rejected")
+	}
+
+	readErr := r.ReadAll(func(row [][]byte) {
+		for _, column := range columns {
+			if err != nil {
+				continue
+			}
+			i := r.position(column)
+			if i == -1 {
+				err = errors.Errorf("column %d can't be reconciled unless it's selected", column)
+				continue
+			}
+			if i >= len(row) {
+				continue
+			}
+			field := bytes.TrimSpace(row[i])
+			if len(field) == 0 {
+				continue
+			}
+			value, parseErr := ParseDecimal(field)
+			if parseErr != nil {
+				err = errors.Wrapf(parseErr, "on record %d, column %d isn't an amount", r.diagnostics.Records, column)
+				continue
+			}
+			sums[column].Add(sums[column], value.Rat())
+		}
+		function(row)
+	})
+	if readErr != nil {
+		return readErr
+	}
+	if err != nil {
+		return err
+	}
+	records := r.diagnostics.Records
+
+	control := c.Control
+	if control == nil {
+		if r.Trailer() == nil {
+			return errors.New("no trailer line was found to reconcile with")
+		}
+		if control, err = c.Format.Parse(r.Trailer()); err != nil {
+			return err
+		}
+	}
+
+	mismatches := []Mismatch{}
+	if control.Records >= 0 && control.Records != records {
+		mismatches = append(mismatches, Mismatch{
+			Name:     "record count",
+			Expected: strconv.Itoa(control.Records),
+			Actual:   strconv.Itoa(records),
+		})
+	}
+	for _, column := range columns {
+		expected, ok := control.Sums[column]
+		if ok && expected.Cmp(sums[column]) != 0 {
+			mismatches = append(mismatches, Mismatch{
+				Name:     fmt.Sprintf("total of column %d", column),
+				Expected: formatRat(expected),
+				Actual:   formatRat(sums[column]),
+			})
+		}
+	}
+
+	if len(mismatches) != 0 {
+		return &ReconciliationError{Mismatches: mismatches}
+	}
+	return nil
+}
+
+// formatRat formats a total as a decimal rather than a fraction.
+func formatRat(r *big.Rat) string {
+	if r.IsInt() {
+		return r.RatString()
+	}
+	return strings.TrimRight(r.FloatString(10), "0")
+}
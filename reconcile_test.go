@@ -0,0 +1,136 @@
+package billdsv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestReconcilerTrailer(t *testing.T) {
+	f := strings.NewReader(`CrNumber|CrPeriod|CrCBRepaymentFee|CrCBBalance
+328|2015/09|-150|4750
+333|2015/11|-150.50|13795
+TRL|2|-300.50|18545
+`)
+
+	cr := NewReader(f, 0, DefaultBufferSize)
+	cr.SkipHeading = true
+	cr.TrailerPrefix = "TRL|"
+
+	c := Reconciler{Format: ControlFormat{Records: 1, Sums: map[int]int{2: 2, 3: 3}}}
+
+	rows := 0
+	err := c.ReadAll(cr, func(row [][]byte) {
+		rows++
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	assert.Equal(t, 2, rows)
+}
+
+func TestReconcilerMismatch(t *testing.T) {
+	f := strings.NewReader(`328|2015/09|-150|4750
+333|2015/11|-150.50|13795
+`)
+
+	control, err := ControlFormat{HasRecords: true, Sums: map[int]int{1: 2, 2: 3}}.ParseFile(strings.NewReader("\n3|-300.50|20000\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := Reconciler{Control: control}
+
+	err = c.ReadAll(NewReader(f, 4, DefaultBufferSize), func(row [][]byte) {})
+
+	rerr, ok := err.(*ReconciliationError)
+	if !ok {
+		t.Fatalf("expected a reconciliation error, got %v", err)
+	}
+	assert.Equal(t, []Mismatch{
+		{Name: "record count", Expected: "3", Actual: "2"},
+		{Name: "total of column 3", Expected: "20000", Actual: "18545"},
+	}, rerr.Mismatches)
+}
+
+func TestReconcilerMissingTrailer(t *testing.T) {
+	f := strings.NewReader("328|2015/09|-150|4750\n")
+
+	cr := NewReader(f, 4, DefaultBufferSize)
+	cr.TrailerPrefix = "TRL|"
+
+	c := Reconciler{Format: ControlFormat{Records: 1}}
+
+	err := c.ReadAll(cr, func(row [][]byte) {})
+	assert.NotEqual(t, nil, err)
+}
+
+func TestReconcilerFormattedAmounts(t *testing.T) {
+	f := strings.NewReader("328|£1,200.10\n329|(200.05)\n330|0.10-\nTRL|3|£999.95\n")
+	cr := NewReader(f, 2, DefaultBufferSize)
+	cr.TrailerPrefix = "TRL|"
+
+	c := &Reconciler{Format: ControlFormat{Records: 1, Sums: map[int]int{2: 1}}}
+	err := c.ReadAll(cr, func([][]byte) {})
+	assert.Equal(t, nil, err)
+}
+
+func TestReconcilerFiltered(t *testing.T) {
+	document := "328|2015/09|Yes\n333|2015/11|No\n340|2016/01|Yes\nTRL|3\n"
+
+	cr := NewReader(strings.NewReader(document), 3, DefaultBufferSize)
+	cr.TrailerPrefix = "TRL|"
+	cr.Filters = []Filter{{Column: "2", Values: []string{"Yes"}}}
+
+	rows := 0
+	c := Reconciler{Format: ControlFormat{Records: 1}}
+	err := c.ReadAll(cr, func(row [][]byte) {
+		rows++
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, rows)
+
+	cr = NewReader(strings.NewReader(document), 3, DefaultBufferSize)
+	cr.TrailerPrefix = "TRL|"
+	cr.TextPolicy = Reject
+
+	c = Reconciler{Format: ControlFormat{Records: 1, Sums: map[int]int{1: 0}}}
+	err = c.ReadAll(cr, func(row [][]byte) {})
+	assert.Equal(t, "totals can't be reconciled while records are filtered or rejected", err.Error())
+}
+
+func TestReconcilerSumsOnly(t *testing.T) {
+	f := strings.NewReader(`328|2015/09|-150|4750
+333|2015/11|-150.50|13795
+TRL|-300.50
+`)
+
+	cr := NewReader(f, 4, DefaultBufferSize)
+	cr.TrailerPrefix = "TRL|"
+
+	c := Reconciler{Format: ControlFormat{Sums: map[int]int{1: 2}}}
+	assert.Equal(t, nil, c.ReadAll(cr, func(row [][]byte) {}))
+
+	control, err := c.Format.Parse([]byte("TRL|-300.50"))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, -1, control.Records)
+}
+
+func TestReconcilerTruncated(t *testing.T) {
+	// the drop was cut off part way through its last record, which would
+	// otherwise make up the count of the sidecar
+	for _, document := range []string{
+		"328|2015/09|-150|4750\n333|2015/11",
+		"328|2015/09|-150|4750\n333|2015/11\n",
+	} {
+		control, err := ControlFormat{HasRecords: true}.ParseFile(strings.NewReader("\n2\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c := Reconciler{Control: control}
+		err = c.ReadAll(NewReader(strings.NewReader(document), 4, DefaultBufferSize), func(row [][]byte) {})
+		assert.Equal(t, "on row 1, expected 4 fields but the document ended after 2", err.Error())
+	}
+}
@@ -0,0 +1,91 @@
+package billdsv
+
+import (
+	"sync"
+)
+
+// Record holds the values of a record, one per field or selected column.
+type Record [][]byte
+
+// Clone returns a copy of the record the caller owns. Its values share a
+// single buffer, so cloning makes two allocations however many values there
+// are.
+func (r Record) Clone() Record {
+	_, record := copyRecord(nil, nil, r)
+	return record
+}
+
+// CopyRow returns a copy of a row passed to ReadAll's function that's safe to
+// keep once the function has returned. Rows, like the values in them, are
+// reused for the next record.
+func CopyRow(row [][]byte) Record {
+	return Record(row).Clone()
+}
+
+// copyRecord copies row into buf and record, reusing their memory when it's
+// large enough. The values' capacity is limited so appending to one can't
+// overwrite the next.
+func copyRecord(buf []byte, record Record, row [][]byte) ([]byte, Record) {
+	n := 0
+	for _, value := range row {
+		n += len(value)
+	}
+	if cap(buf) < n {
+		buf = make([]byte, 0, n)
+	}
+	if cap(record) < len(row) {
+		record = make(Record, 0, len(row))
+	}
+
+	buf = buf[:0]
+	record = record[:0]
+	for _, value := range row {
+		start := len(buf)
+		buf = append(buf, value...)
+		record = append(record, buf[start:len(buf):len(buf)])
+	}
+	return buf, record
+}
+
+// RecordPool copies records into buffers that are recycled once released, so
+// code that keeps copies of records for a while, such as a queue of records
+// to process, doesn't allocate for each of them.
+type RecordPool struct {
+	pool sync.Pool
+}
+
+// PooledRecord is a copy of a record from a RecordPool.
+type PooledRecord struct {
+	Record Record
+
+	buf  []byte
+	pool *RecordPool
+}
+
+// Copy copies row into a buffer from the pool. Release the copy once it's no
+// longer needed.
+func (p *RecordPool) Copy(row [][]byte) *PooledRecord {
+	r, _ := p.pool.Get().(*PooledRecord)
+	if r == nil {
+		r = &PooledRecord{pool: p}
+	}
+	r.buf, r.Record = copyRecord(r.buf, r.Record, row)
+	return r
+}
+
+// Release returns the copy's buffer to its pool, after which neither it nor
+// its values may be used.
+func (r *PooledRecord) Release() {
+	r.pool.pool.Put(r)
+}
+
+// poisonByte overwrites reused buffers when the reader's Poison is set.
+const poisonByte = 0xde
+
+// poison overwrites the whole of a buffer, up to its capacity.
+func poison(b []byte) {
+	b = b[:cap(b)]
+	for i := range b {
+		b[i] = poisonByte
+	}
+}
@@ -0,0 +1,115 @@
+package billdsv
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestRecordClone(t *testing.T) {
+	row := [][]byte{[]byte("1"), nil, []byte("Amy")}
+	record := Record(row).Clone()
+	assert.Equal(t, Record{[]byte("1"), []byte{}, []byte("Amy")}, record)
+
+	// the copy doesn't share the row's memory
+	row[2][0] = 'E'
+	assert.Equal(t, "Amy", string(record[2]))
+
+	// nor can appending to a value overwrite the next
+	record[0] = append(record[0], '0')
+	assert.Equal(t, "Amy", string(record[2]))
+
+	allocs := testing.AllocsPerRun(100, func() {
+		CopyRow(row)
+	})
+	assert.Equal(t, 2.0, allocs)
+}
+
+func TestReaderCopyRow(t *testing.T) {
+	cr := NewReader(strings.NewReader(batchDocument), 3, DefaultBufferSize)
+
+	kept, copied := [][][]byte{}, []Record{}
+	err := cr.ReadAll(func(row [][]byte) {
+		kept = append(kept, row)
+		copied = append(copied, CopyRow(row))
+	})
+	assert.Equal(t, nil, err)
+
+	// every row kept is the same reused row, only the copies are intact
+	assert.Equal(t, "5", string(kept[0][0]))
+	assert.Equal(t, "1", string(copied[0][0]))
+	assert.Equal(t, "Bob\nline", string(copied[1][1]))
+}
+
+func TestRecordPool(t *testing.T) {
+	var pool RecordPool
+	row := [][]byte{[]byte("1"), []byte("Amy"), []byte("first")}
+
+	r := pool.Copy(row)
+	assert.Equal(t, Record(row), r.Record)
+	r.Release()
+
+	allocs := testing.AllocsPerRun(100, func() {
+		pool.Copy(row).Release()
+	})
+	if allocs > 0 {
+		t.Errorf("expected pooled copies not to allocate, got %v allocations", allocs)
+	}
+}
+
+func poisoned(value []byte) bool {
+	return len(value) > 0 && len(bytes.Trim(value, "\xde")) == 0
+}
+
+func TestReaderPoison(t *testing.T) {
+	cr := NewReader(strings.NewReader(batchDocument), 3, DefaultBufferSize)
+	cr.Poison = true
+
+	var kept, copied [][]byte
+	err := cr.ReadAll(func(row [][]byte) {
+		if kept == nil {
+			kept = [][]byte{row[1]}
+			copied = CopyRow(row)
+		}
+		assert.Equal(t, false, poisoned(row[1]))
+	})
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, true, poisoned(kept[0]))
+	assert.Equal(t, "Amy", string(copied[1]))
+}
+
+func TestBatcherPoison(t *testing.T) {
+	cr := NewReader(strings.NewReader(batchDocument), 3, DefaultBufferSize)
+	cr.Poison = true
+
+	var kept Record
+	err := (&Batcher{Size: 2}).ReadAll(cr, func(batch []Record) error {
+		if kept == nil {
+			kept = batch[0]
+		}
+		return nil
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, poisoned(kept[1]))
+}
+
+func TestPipelinePoison(t *testing.T) {
+	cr := NewReader(strings.NewReader(batchDocument), 3, DefaultBufferSize)
+	cr.Poison = true
+
+	var kept Record
+	err := (&Pipeline{Workers: 1}).ReadAll(context.Background(), cr, func(ctx context.Context, record Record) (interface{}, error) {
+		return nil, nil
+	}, func(record Record, _ interface{}) error {
+		if kept == nil {
+			kept = record
+		}
+		return nil
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, poisoned(kept[1]))
+}
@@ -0,0 +1,493 @@
+package billdsv
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// reportSamples is the number of violations of a rule kept as samples.
+const reportSamples = 5
+
+// Rule is a data-quality check on the values of a column. Unlike the column's
+// type, which must be satisfied for a record to be read at all, broken rules
+// are collected in the reader's Report and reading carries on, see
+// MaxViolations. Empty values only break Required, every other check skips
+// them.
+type Rule struct {
+	// Name is reported in place of a description of the check that failed.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+
+	// Required values may not be empty.
+	Required bool `json:"required,omitempty" yaml:"required,omitempty"`
+
+	// Pattern is a regular expression the whole value must match.
+	Pattern string `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+
+	// MinLength and MaxLength bound the number of characters in a value,
+	// zero for no bound.
+	MinLength int `json:"minLength,omitempty" yaml:"minLength,omitempty"`
+	MaxLength int `json:"maxLength,omitempty" yaml:"maxLength,omitempty"`
+
+	// Min and Max are the inclusive range of values, written the way the
+	// column's values are. Int, decimal, date, datetime and period columns
+	// are compared by value and any others as text.
+	Min string `json:"min,omitempty" yaml:"min,omitempty"`
+	Max string `json:"max,omitempty" yaml:"max,omitempty"`
+
+	// Values are the values allowed.
+	Values []string `json:"values,omitempty" yaml:"values,omitempty"`
+
+	// Unique values may only appear once in the document.
+	Unique bool `json:"unique,omitempty" yaml:"unique,omitempty"`
+
+	// After names another column the value must be after, such as an end
+	// date after its start date. Records where either is empty, or a
+	// sentinel date, are skipped.
+	After string `json:"after,omitempty" yaml:"after,omitempty"`
+
+	// Check is a custom check that's given the whole record, for rules that
+	// can't be declared.
+	Check func(value []byte, row Row) error `json:"-" yaml:"-"`
+}
+
+// Violation is a value that broke a rule.
+type Violation struct {
+	Record  int    `json:"record"`
+	Value   string `json:"value"`
+	Message string `json:"message"`
+}
+
+// RuleReport is the number of times a rule was broken along with the first
+// few violations.
+type RuleReport struct {
+	Rule       string      `json:"rule"`
+	Violations int         `json:"violations"`
+	Samples    []Violation `json:"samples,omitempty"`
+}
+
+// ColumnReport is the outcome of the rules of a column.
+type ColumnReport struct {
+	Column     string       `json:"column"`
+	Violations int          `json:"violations"`
+	Rules      []RuleReport `json:"rules"`
+}
+
+// Report is the outcome of checking a document against the rules and
+// transforms of its schema's columns.
+type Report struct {
+	Records    int            `json:"records"`
+	Violations int            `json:"violations"`
+	Columns    []ColumnReport `json:"columns"`
+}
+
+// WriteJSON writes the report as indented JSON.
+func (p *Report) WriteJSON(w io.Writer) error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// WriteSummary writes a summary of the broken rules of each column for
+// people to read, along with sample record numbers.
+func (p *Report) WriteSummary(w io.Writer) error {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("%d records, %s\n", p.Records, plural(p.Violations, "rule violation")))
+	for _, c := range p.Columns {
+		if c.Violations == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s: %s\n", c.Column, plural(c.Violations, "violation")))
+		for _, r := range c.Rules {
+			if r.Violations == 0 {
+				continue
+			}
+			records := make([]string, len(r.Samples))
+			for i, v := range r.Samples {
+				records[i] = strconv.Itoa(v.Record)
+			}
+			more := ""
+			if r.Violations > len(r.Samples) {
+				more = ", ..."
+			}
+			sb.WriteString(fmt.Sprintf("  %s: %s on records %s%s\n", r.Rule, plural(r.Violations, "violation"), strings.Join(records, ", "), more))
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return strconv.Itoa(n) + " " + noun + "s"
+}
+
+// ruleCheck is a single check of a rule, reported under its column and rule
+// name.
+type ruleCheck struct {
+	column int
+	report [2]int
+	check  func(record int, value []byte, row Row) error
+}
+
+// columnTransform is the transform of a column, whose invalid values are
+// reported under the transform's name.
+type columnTransform struct {
+	column    int
+	report    [2]int
+	transform Transform
+}
+
+// compileRules sets up the transforms and the checks of every rule of the
+// schema's columns and the report they're collected in.
+func (s *Schema) compileRules() ([]columnTransform, []ruleCheck, Report, error) {
+	s.init()
+
+	var (
+		transforms []columnTransform
+		checks     []ruleCheck
+		report     = Report{Columns: []ColumnReport{}}
+	)
+	for i := range s.Columns {
+		column := &s.Columns[i]
+		if len(column.Rules) == 0 && column.Transform == "" {
+			continue
+		}
+
+		c := len(report.Columns)
+		report.Columns = append(report.Columns, ColumnReport{Column: column.Name, Rules: []RuleReport{}})
+		rules := map[string]int{}
+
+		if column.Transform != "" {
+			t, err := lookupTransform(column.Transform)
+			if err != nil {
+				return nil, nil, Report{}, errors.Wrapf(err, "column %s", column.Name)
+			}
+			rules["transform "+column.Transform] = 0
+			report.Columns[c].Rules = append(report.Columns[c].Rules, RuleReport{Rule: "transform " + column.Transform})
+			transforms = append(transforms, columnTransform{column: i, report: [2]int{c, 0}, transform: t})
+		}
+
+		for j := range column.Rules {
+			rule := &column.Rules[j]
+			named, err := rule.checks(s, column)
+			if err != nil {
+				return nil, nil, Report{}, errors.Wrapf(err, "rule of column %s", column.Name)
+			}
+
+			for _, n := range named {
+				name := n.name
+				if rule.Name != "" {
+					name = rule.Name
+				}
+				r, ok := rules[name]
+				if !ok {
+					r = len(report.Columns[c].Rules)
+					rules[name] = r
+					report.Columns[c].Rules = append(report.Columns[c].Rules, RuleReport{Rule: name})
+				}
+				checks = append(checks, ruleCheck{column: i, report: [2]int{c, r}, check: n.check})
+			}
+		}
+	}
+	return transforms, checks, report, nil
+}
+
+type namedCheck struct {
+	name  string
+	check func(record int, value []byte, row Row) error
+}
+
+// checks returns the checks making up a rule of a column.
+func (rule *Rule) checks(s *Schema, column *Column) ([]namedCheck, error) {
+	checks := []namedCheck{}
+	add := func(name string, check func(record int, value []byte, row Row) error) {
+		checks = append(checks, namedCheck{name: name, check: func(record int, value []byte, row Row) error {
+			if len(value) == 0 {
+				return nil
+			}
+			return check(record, value, row)
+		}})
+	}
+
+	if rule.Required {
+		checks = append(checks, namedCheck{name: "required", check: func(_ int, value []byte, _ Row) error {
+			if len(value) == 0 {
+				return errors.New("value is required")
+			}
+			return nil
+		}})
+	}
+
+	if rule.Pattern != "" {
+		re, err := regexp.Compile("^(?:" + rule.Pattern + ")$")
+		if err != nil {
+			return nil, err
+		}
+		add("pattern "+rule.Pattern, func(_ int, value []byte, _ Row) error {
+			if !re.Match(value) {
+				return errors.Errorf("value doesn't match %s", rule.Pattern)
+			}
+			return nil
+		})
+	}
+
+	if rule.MinLength > 0 || rule.MaxLength > 0 {
+		min, max := "", ""
+		if rule.MinLength > 0 {
+			min = strconv.Itoa(rule.MinLength)
+		}
+		if rule.MaxLength > 0 {
+			max = strconv.Itoa(rule.MaxLength)
+		}
+		add("length "+bounds(min, max), func(_ int, value []byte, _ Row) error {
+			n := utf8.RuneCount(value)
+			if n < rule.MinLength || (rule.MaxLength > 0 && n > rule.MaxLength) {
+				return errors.Errorf("value is %d characters", n)
+			}
+			return nil
+		})
+	}
+
+	if rule.Min != "" || rule.Max != "" {
+		var min, max ordered
+		for _, b := range []struct {
+			value string
+			o     *ordered
+		}{{rule.Min, &min}, {rule.Max, &max}} {
+			if b.value == "" {
+				continue
+			}
+			o, ok, err := order(column, []byte(b.value))
+			if err != nil || !ok {
+				return nil, errors.Errorf("%q isn't a value of the column", b.value)
+			}
+			*b.o = o
+		}
+
+		add("range "+bounds(rule.Min, rule.Max), func(_ int, value []byte, _ Row) error {
+			o, ok, err := order(column, value)
+			switch {
+			case err != nil:
+				return err
+			case !ok:
+				return nil
+			case rule.Min != "" && o.cmp(min) < 0:
+				return errors.Errorf("value is less than %s", rule.Min)
+			case rule.Max != "" && o.cmp(max) > 0:
+				return errors.Errorf("value is more than %s", rule.Max)
+			}
+			return nil
+		})
+	}
+
+	if rule.Values != nil {
+		values := make(map[string]bool, len(rule.Values))
+		for _, v := range rule.Values {
+			values[v] = true
+		}
+		add("one of "+strings.Join(rule.Values, ", "), func(_ int, value []byte, _ Row) error {
+			if !values[string(value)] {
+				return errors.Errorf("value isn't one of %s", strings.Join(rule.Values, ", "))
+			}
+			return nil
+		})
+	}
+
+	if rule.Unique {
+		seen := map[string]int{}
+		add("unique", func(record int, value []byte, _ Row) error {
+			if first, ok := seen[string(value)]; ok {
+				return errors.Errorf("value was first seen on record %d", first)
+			}
+			seen[string(value)] = record
+			return nil
+		})
+	}
+
+	if rule.After != "" {
+		i := s.Index(rule.After)
+		if i == -1 {
+			return nil, errors.Errorf("no column named %q to be after", rule.After)
+		}
+		other := &s.Columns[i]
+		if kind(column) != kind(other) {
+			return nil, errors.Errorf("can't compare a %s column with %s, a %s column", column.Type, other.Name, other.Type)
+		}
+
+		add("after "+rule.After, func(_ int, value []byte, row Row) error {
+			o, ok, err := order(column, value)
+			if err != nil || !ok || i >= len(row.Fields) {
+				return err
+			}
+			p, ok, err := order(other, row.Fields[i])
+			if err != nil || !ok {
+				return err
+			}
+			if o.cmp(p) <= 0 {
+				return errors.Errorf("value isn't after %s %s", rule.After, row.Fields[i])
+			}
+			return nil
+		})
+	}
+
+	if rule.Check != nil {
+		add("check", func(_ int, value []byte, row Row) error {
+			return rule.Check(value, row)
+		})
+	}
+
+	return checks, nil
+}
+
+// bounds describes a range, either bound may be empty.
+func bounds(min, max string) string {
+	switch {
+	case max == "":
+		return "at least " + min
+	case min == "":
+		return "at most " + max
+	}
+	return min + " to " + max
+}
+
+// ordered is a value of a column that can be compared with other values of
+// columns of the same kind.
+type ordered struct {
+	kind    int
+	decimal Decimal
+	time    time.Time
+	text    []byte
+}
+
+// Kinds of ordered values.
+const (
+	textOrder = iota
+	decimalOrder
+	timeOrder
+)
+
+// kind returns how the values of a column are compared.
+func kind(c *Column) int {
+	switch c.Type {
+	case IntType, DecimalType:
+		return decimalOrder
+	case DateType, DateTimeType, PeriodType:
+		return timeOrder
+	}
+	return textOrder
+}
+
+// order returns a value of a column that can be compared, it's not ok if the
+// value is empty or a sentinel date.
+func order(c *Column, value []byte) (o ordered, ok bool, err error) {
+	if len(value) == 0 {
+		return o, false, nil
+	}
+
+	o.kind = kind(c)
+	switch {
+	case o.kind == decimalOrder:
+		o.decimal, err = c.decimal(value)
+	case c.Type == PeriodType:
+		var p Period
+		p, err = ParsePeriod(value)
+		o.time = p.Start(time.UTC)
+	case o.kind == timeOrder:
+		var (
+			dates DateCodec
+			nt    sql.NullTime
+		)
+		if dates, err = c.dates(); err == nil {
+			nt, err = dates.Decode(value)
+		}
+		if err != nil || !nt.Valid {
+			return o, false, err
+		}
+		o.time = nt.Time
+	default:
+		o.text = value
+	}
+	return o, err == nil, err
+}
+
+func (o ordered) cmp(p ordered) int {
+	switch o.kind {
+	case textOrder:
+		return bytes.Compare(o.text, p.text)
+	case timeOrder:
+		switch {
+		case o.time.Before(p.time):
+			return -1
+		case o.time.After(p.time):
+			return 1
+		}
+		return 0
+	}
+	return o.decimal.Cmp(p.decimal)
+}
+
+// Report returns the outcome of the rules of the schema's columns, once
+// ReadAll has returned.
+func (r *Reader) Report() *Report {
+	return &r.report
+}
+
+// checkRules checks the record against every rule.
+func (r *Reader) checkRules() error {
+	row := r.Schema.Row(r.rowBuffer)
+	for _, c := range r.rules {
+		if c.column >= len(r.rowBuffer) || r.unselected(c.column) {
+			continue
+		}
+		if err := c.check(r.diagnostics.Records, r.rowBuffer[c.column], row); err != nil {
+			if err := r.violation(c.column, c.report, err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// violation adds a broken rule to the report, returning a *ValidationError
+// once MaxViolations rules have been broken.
+func (r *Reader) violation(i int, report [2]int, err error) error {
+	value := r.rowBuffer[i]
+	column := &r.report.Columns[report[0]]
+	rule := &column.Rules[report[1]]
+
+	r.report.Violations++
+	column.Violations++
+	rule.Violations++
+	if len(rule.Samples) < reportSamples {
+		rule.Samples = append(rule.Samples, Violation{
+			Record:  r.diagnostics.Records,
+			Value:   string(value),
+			Message: err.Error(),
+		})
+	}
+
+	if r.MaxViolations > 0 && r.report.Violations >= r.MaxViolations {
+		return &ValidationError{
+			Record: r.diagnostics.Records,
+			Column: column.Column,
+			Value:  string(value),
+			Err:    errors.Wrap(err, rule.Rule),
+		}
+	}
+	return nil
+}
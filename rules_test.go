@@ -0,0 +1,110 @@
+package billdsv
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+	"github.com/pkg/errors"
+)
+
+const contracts = `Number|Start|End|Amount|Scheme|Exec
+328|2015-09-01|2016-09-01|-150|Scheme3|006308
+329|2015-09-01|2015-08-01|20000|Scheme9|006308
+329|2015-10-01|1899-12-30|4750|Scheme3|
+330|2015-11-01|2015-11-01|13795|Scheme1|0063
+`
+
+func contractSchema() *Schema {
+	return &Schema{Columns: []Column{
+		{Name: "Number", Type: IntType, Rules: []Rule{{Unique: true}}},
+		{Name: "Start", Type: DateType, Rules: []Rule{{Min: "2015-01-01", Max: "2015-12-31"}}},
+		{Name: "End", Type: DateType, Rules: []Rule{{After: "Start"}}},
+		{Name: "Amount", Type: DecimalType, Rules: []Rule{{Min: "-500", Max: "15000"}}},
+		{Name: "Scheme", Type: StringType, Rules: []Rule{{Name: "known scheme", Values: []string{"Scheme1", "Scheme3"}}}},
+		{Name: "Exec", Type: StringType, Nullable: true, Rules: []Rule{{Required: true, Pattern: `\d+`, MinLength: 6, MaxLength: 6}}},
+	}}
+}
+
+func TestRules(t *testing.T) {
+	cr := NewSchemaReader(strings.NewReader(contracts), contractSchema(), DefaultBufferSize)
+	cr.SkipHeading = true
+
+	rows := 0
+	err := cr.ReadAll(func([][]byte) {
+		rows++
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 4, rows)
+
+	report := cr.Report()
+	assert.Equal(t, 4, report.Records)
+	assert.Equal(t, 7, report.Violations)
+	assert.Equal(t, ColumnReport{Column: "Number", Violations: 1, Rules: []RuleReport{
+		{Rule: "unique", Violations: 1, Samples: []Violation{{Record: 3, Value: "329", Message: "value was first seen on record 2"}}},
+	}}, report.Columns[0])
+	assert.Equal(t, ColumnReport{Column: "Exec", Violations: 2, Rules: []RuleReport{
+		{Rule: "required", Violations: 1, Samples: []Violation{{Record: 3, Value: "", Message: "value is required"}}},
+		{Rule: "pattern \\d+"},
+		{Rule: "length 6 to 6", Violations: 1, Samples: []Violation{{Record: 4, Value: "0063", Message: "value is 4 characters"}}},
+	}}, report.Columns[5])
+
+	b := &bytes.Buffer{}
+	assert.Equal(t, nil, report.WriteSummary(b))
+	assert.Equal(t, `4 records, 7 rule violations
+Number: 1 violation
+  unique: 1 violation on records 3
+End: 2 violations
+  after Start: 2 violations on records 2, 4
+Amount: 1 violation
+  range -500 to 15000: 1 violation on records 2
+Scheme: 1 violation
+  known scheme: 1 violation on records 2
+Exec: 2 violations
+  required: 1 violation on records 3
+  length 6 to 6: 1 violation on records 4
+`, b.String())
+
+	b.Reset()
+	assert.Equal(t, nil, report.WriteJSON(b))
+	assert.Equal(t, true, strings.Contains(b.String(), `"rule": "after Start",`))
+}
+
+func TestRulesMaxViolations(t *testing.T) {
+	s := contractSchema()
+	s.Columns[0].Rules = append(s.Columns[0].Rules, Rule{Check: func(value []byte, row Row) error {
+		if scheme, _ := row.String("Scheme"); scheme == "Scheme9" {
+			return errors.New("scheme 9 contracts are numbered from 900")
+		}
+		return nil
+	}})
+
+	cr := NewSchemaReader(strings.NewReader(contracts), s, DefaultBufferSize)
+	cr.SkipHeading = true
+	cr.MaxViolations = 2
+
+	rows := 0
+	err := cr.ReadAll(func([][]byte) {
+		rows++
+	})
+
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	assert.Equal(t, 1, rows)
+	assert.Equal(t, "on record 2, column End: after Start: value isn't after Start 2015-09-01", verr.Error())
+	assert.Equal(t, 2, cr.Report().Violations)
+	assert.Equal(t, "scheme 9 contracts are numbered from 900", cr.Report().Columns[0].Rules[1].Samples[0].Message)
+}
+
+func TestRulesInvalid(t *testing.T) {
+	s := contractSchema()
+	s.Columns[2].Rules[0].After = "Amount"
+
+	cr := NewSchemaReader(strings.NewReader(contracts), s, DefaultBufferSize)
+	cr.SkipHeading = true
+	err := cr.ReadAll(func([][]byte) {})
+	assert.Equal(t, "rule of column End: can't compare a date column with Amount, a decimal column", err.Error())
+}
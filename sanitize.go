@@ -0,0 +1,164 @@
+package billdsv
+
+import (
+	"unicode/utf8"
+)
+
+// TextPolicy controls how values containing invalid UTF-8 or control
+// characters are handled. Policies may be combined, for example
+// ReplaceInvalid|StripControl, and PassThrough leaves values untouched.
+type TextPolicy int
+
+const (
+	// ReplaceInvalid replaces each invalid UTF-8 byte with U+FFFD.
+	ReplaceInvalid TextPolicy = 1 << iota
+	// StripControl removes control characters, including NUL and the C1
+	// controls, but keeps tabs and line breaks since values may span lines.
+	StripControl
+	// Reject drops records with a value containing invalid UTF-8 or control
+	// characters rather than passing them to ReadAll's function.
+	Reject
+)
+
+// PassThrough leaves values as they were read.
+const PassThrough TextPolicy = 0
+
+// Diagnostics counts the problems found while reading.
+type Diagnostics struct {
+	// Records is the number of records read, including rejected records.
+	Records int
+	// Rejected is the number of records dropped by a Reject policy.
+	Rejected int
+	// Filtered is the number of records dropped by the reader's Filters.
+	Filtered int
+	// InvalidUTF8 is the number of values containing invalid UTF-8.
+	InvalidUTF8 int
+	// ControlCharacters is the number of values containing control characters.
+	ControlCharacters int
+}
+
+// Diagnostics returns the counts gathered by ReadAll. Values are only checked
+// for invalid UTF-8 and control characters when a policy other than
+// PassThrough applies to their column.
+func (r *Reader) Diagnostics() Diagnostics {
+	return r.diagnostics
+}
+
+// drops reports whether the reader may drop records, either through its
+// Filters or a Reject policy.
+func (r *Reader) drops() bool {
+	if len(r.Filters) > 0 || r.TextPolicy&Reject != 0 {
+		return true
+	}
+	for _, p := range r.ColumnPolicies {
+		if p&Reject != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// policy returns the text policy that applies to a column.
+func (r *Reader) policy(column int) TextPolicy {
+	if p, ok := r.ColumnPolicies[column]; ok {
+		return p
+	}
+	return r.TextPolicy
+}
+
+// sanitize applies the text policies to the row buffer, reporting whether the
+// record should be kept.
+func (r *Reader) sanitize() bool {
+	for i, field := range r.rowBuffer {
+		p := r.policy(i)
+		if p == PassThrough {
+			continue
+		}
+
+		invalid, control := inspect(field)
+		if invalid {
+			r.diagnostics.InvalidUTF8++
+		}
+		if control {
+			r.diagnostics.ControlCharacters++
+		}
+
+		if !invalid && !control {
+			continue
+		}
+		if p&Reject != 0 {
+			return false
+		}
+		if (invalid && p&ReplaceInvalid != 0) || (control && p&StripControl != 0) {
+			r.scratch = clean(r.scratch[:0], field, p)
+			r.rowBuffer[i] = append(r.rowBuffer[i][:0], r.scratch...)
+		}
+	}
+
+	return true
+}
+
+// inspect reports whether a value contains invalid UTF-8 or control
+// characters.
+func inspect(field []byte) (invalid, control bool) {
+	for i := 0; i < len(field); {
+		c := field[i]
+		if c < utf8.RuneSelf {
+			if isControl(rune(c)) {
+				control = true
+			}
+			i++
+			continue
+		}
+
+		rn, size := utf8.DecodeRune(field[i:])
+		if rn == utf8.RuneError && size == 1 {
+			invalid = true
+		} else if isControl(rn) {
+			control = true
+		}
+		i += size
+	}
+	return invalid, control
+}
+
+// clean appends field to dst, replacing invalid UTF-8 and removing control
+// characters as the policy dictates.
+func clean(dst, field []byte, p TextPolicy) []byte {
+	for i := 0; i < len(field); {
+		c := field[i]
+		if c < utf8.RuneSelf {
+			if !isControl(rune(c)) || p&StripControl == 0 {
+				dst = append(dst, c)
+			}
+			i++
+			continue
+		}
+
+		rn, size := utf8.DecodeRune(field[i:])
+		switch {
+		case rn == utf8.RuneError && size == 1:
+			if p&ReplaceInvalid != 0 {
+				dst = append(dst, string(utf8.RuneError)...)
+			} else {
+				dst = append(dst, c)
+			}
+		case isControl(rn) && p&StripControl != 0:
+		default:
+			dst = append(dst, field[i:i+size]...)
+		}
+		i += size
+	}
+	return dst
+}
+
+func isControl(rn rune) bool {
+	switch {
+	case rn == '\t' || rn == '\n' || rn == '\r':
+		return false
+	case rn < 0x20 || rn == 0x7f:
+		return true
+	default:
+		return rn >= 0x80 && rn < 0xa0
+	}
+}
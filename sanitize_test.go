@@ -0,0 +1,68 @@
+package billdsv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+const dirtyComms = "7860442|Customer\x00 has \x1bcancelled|SYSTEM\n" +
+	"7860462|\xc0\xf5 Appointment\u0085 booked|SYST\xc0M\n" +
+	"7860482|Install date\tconfirmed|SYSTEM\n"
+
+func readSanitized(t *testing.T, configure func(cr *Reader)) ([][]string, Diagnostics) {
+	cr := NewReader(strings.NewReader(dirtyComms), 3, DefaultBufferSize)
+	configure(cr)
+
+	got := [][]string{}
+	err := cr.ReadAll(func(row [][]byte) {
+		rowStrings := make([]string, 3)
+		for i, c := range row {
+			rowStrings[i] = string(c)
+		}
+		got = append(got, rowStrings)
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	return got, cr.Diagnostics()
+}
+
+func TestReaderPassThrough(t *testing.T) {
+	got, diagnostics := readSanitized(t, func(cr *Reader) {})
+
+	assert.Equal(t, "Customer\x00 has \x1bcancelled", got[0][1])
+	assert.Equal(t, Diagnostics{Records: 3}, diagnostics)
+}
+
+func TestReaderReplaceAndStrip(t *testing.T) {
+	got, diagnostics := readSanitized(t, func(cr *Reader) {
+		cr.TextPolicy = ReplaceInvalid | StripControl
+		cr.ColumnPolicies = map[int]TextPolicy{2: PassThrough}
+	})
+
+	want := [][]string{
+		{"7860442", "Customer has cancelled", "SYSTEM"},
+		{"7860462", "�� Appointment booked", "SYST\xc0M"},
+		{"7860482", "Install date\tconfirmed", "SYSTEM"},
+	}
+
+	assert.Equal(t, want, got)
+	assert.Equal(t, Diagnostics{Records: 3, InvalidUTF8: 1, ControlCharacters: 2}, diagnostics)
+}
+
+func TestReaderReject(t *testing.T) {
+	got, diagnostics := readSanitized(t, func(cr *Reader) {
+		cr.ColumnPolicies = map[int]TextPolicy{2: Reject}
+	})
+
+	want := [][]string{
+		{"7860442", "Customer\x00 has \x1bcancelled", "SYSTEM"},
+		{"7860482", "Install date\tconfirmed", "SYSTEM"},
+	}
+
+	assert.Equal(t, want, got)
+	assert.Equal(t, Diagnostics{Records: 3, Rejected: 1, InvalidUTF8: 1}, diagnostics)
+}
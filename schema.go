@@ -0,0 +1,474 @@
+package billdsv
+
+import (
+	"bytes"
+	"database/sql"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Type is the type of the values in a column.
+type Type string
+
+// The column types a schema may use.
+const (
+	StringType   Type = "string"
+	IntType      Type = "int"
+	DecimalType  Type = "decimal"
+	DateType     Type = "date"
+	DateTimeType Type = "datetime"
+	BoolType     Type = "bool"
+	EnumType     Type = "enum"
+	PeriodType   Type = "period"
+)
+
+// The layouts used for date and datetime columns without their own.
+var (
+	DefaultDateLayout     = "2006-01-02"
+	DefaultDateTimeLayout = "2006-01-02 15:04:05"
+)
+
+// Column describes a single column of a document.
+type Column struct {
+	Name string `json:"name" yaml:"name"`
+	Type Type   `json:"type" yaml:"type"`
+
+	// Nullable columns may be empty, an empty value in any other column is
+	// a validation error.
+	Nullable bool `json:"nullable,omitempty" yaml:"nullable,omitempty"`
+
+	// MaxLength is the maximum number of characters in a value, zero for no
+	// limit.
+	MaxLength int `json:"maxLength,omitempty" yaml:"maxLength,omitempty"`
+
+	// MultiLine columns may contain line breaks.
+	MultiLine bool `json:"multiLine,omitempty" yaml:"multiLine,omitempty"`
+
+	// Layout is the time layout of date and datetime columns. It's the
+	// layout dates are written in and the first tried when reading them.
+	Layout string `json:"layout,omitempty" yaml:"layout,omitempty"`
+
+	// Layouts are further layouts tried in order when reading dates, for
+	// columns that mix them.
+	Layouts []string `json:"layouts,omitempty" yaml:"layouts,omitempty"`
+
+	// Location is the time zone name of dates without an offset, such as
+	// "Europe/London", they're UTC when it isn't set.
+	Location string `json:"location,omitempty" yaml:"location,omitempty"`
+
+	// ImpliedDecimals is the number of decimal places implied in decimal
+	// values written without a decimal point, 2 for amounts in pence.
+	ImpliedDecimals int `json:"impliedDecimals,omitempty" yaml:"impliedDecimals,omitempty"`
+
+	// Values are the values allowed in an enum column.
+	Values []string `json:"values,omitempty" yaml:"values,omitempty"`
+
+	// Enum names one of the schema's Enums holding the codes allowed in an
+	// enum column, in place of Values.
+	Enum string `json:"enum,omitempty" yaml:"enum,omitempty"`
+
+	// TrueValues and FalseValues are the values of a bool column meaning true
+	// and false, compared ignoring case, the first of each being written when
+	// encoding. Columns without them read 1, Y, Yes, T and True as true and 0,
+	// N, No, F and False as false, and are written as Yes and No.
+	TrueValues  []string `json:"trueValues,omitempty" yaml:"trueValues,omitempty"`
+	FalseValues []string `json:"falseValues,omitempty" yaml:"falseValues,omitempty"`
+
+	// Sentinels are the dates meaning there's no date in a date or datetime
+	// column, DefaultDateSentinels are used when there are none.
+	Sentinels []string `json:"sentinels,omitempty" yaml:"sentinels,omitempty"`
+
+	// Transform names a registered transform that normalises the column's
+	// values before they're validated, see RegisterTransform.
+	Transform string `json:"transform,omitempty" yaml:"transform,omitempty"`
+
+	// Rules are data-quality checks reported by the reader rather than
+	// stopping it, see Rule.
+	Rules []Rule `json:"rules,omitempty" yaml:"rules,omitempty"`
+
+	// Composite splits values that pack several values into one into parts.
+	Composite *Composite `json:"composite,omitempty" yaml:"composite,omitempty"`
+
+	codes *enumCodes
+
+	// the codec of a date or datetime column, resolved once by Schema.init
+	codec    *DateCodec
+	codecErr error
+}
+
+// dates returns the codec for a date or datetime column.
+func (c *Column) dates() (DateCodec, error) {
+	if c.codec != nil {
+		return *c.codec, c.codecErr
+	}
+	return c.newDates()
+}
+
+// newDates makes the codec for a date or datetime column, loading its
+// location and parsing its sentinels.
+func (c *Column) newDates() (DateCodec, error) {
+	codec := DateCodec{Layout: c.layout(), Layouts: c.Layouts, Sentinels: c.Sentinels}
+	if codec.Sentinels == nil {
+		codec.Sentinels = DefaultDateSentinels
+	}
+
+	if c.Location != "" {
+		loc, err := LoadLocation(c.Location)
+		if err != nil {
+			return codec, err
+		}
+		codec.Location = loc
+	}
+	return codec.compile(), nil
+}
+
+// decimal parses a value of a decimal column.
+func (c *Column) decimal(value []byte) (Decimal, error) {
+	return ParseDecimalImplied(value, c.ImpliedDecimals)
+}
+
+func (c *Column) layout() string {
+	switch {
+	case c.Layout != "":
+		return c.Layout
+	case c.Type == DateTimeType:
+		return DefaultDateTimeLayout
+	default:
+		return DefaultDateLayout
+	}
+}
+
+// validate checks a single value against the column.
+func (c *Column) validate(value []byte) error {
+	if len(value) == 0 {
+		if !c.Nullable {
+			return errors.New("value is required")
+		}
+		return nil
+	}
+
+	if c.MaxLength > 0 && utf8.RuneCount(value) > c.MaxLength {
+		return errors.Errorf("value is longer than %d characters", c.MaxLength)
+	}
+	if !c.MultiLine && bytes.ContainsAny(value, "\r\n") {
+		return errors.New("value contains a line break")
+	}
+
+	var err error
+	switch c.Type {
+	case IntType:
+		_, err = strconv.ParseInt(string(value), 10, 64)
+	case DecimalType:
+		_, err = c.decimal(value)
+	case DateType, DateTimeType:
+		var dates DateCodec
+		if dates, err = c.dates(); err == nil {
+			_, err = dates.Decode(value)
+		}
+	case PeriodType:
+		_, err = ParsePeriod(value)
+	case BoolType:
+		_, err = c.bool(value)
+	case EnumType:
+		_, err = c.enum(value)
+	}
+	if err == nil && c.Composite != nil {
+		err = c.Composite.validate(value)
+	}
+	return err
+}
+
+// Schema describes the columns of a document. It takes the place of the bare
+// field count given to NewReader, see NewSchemaReader.
+type Schema struct {
+	Columns []Column `json:"columns" yaml:"columns"`
+
+	// Enums are the enums shared by the schema's enum columns.
+	Enums []Enum `json:"enums,omitempty" yaml:"enums,omitempty"`
+
+	once     sync.Once
+	index    map[string]int
+	decoders sync.Map
+}
+
+// ReadSchema reads a schema written as YAML or JSON, such as the output of
+// Profile.WriteYAML or Profile.WriteJSON once it has been reviewed.
+func ReadSchema(r io.Reader) (*Schema, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// YAML is a superset of JSON so both are read the same way
+	s := &Schema{}
+	if err := yaml.Unmarshal(b, s); err != nil {
+		return nil, errors.Wrap(err, "failed to read schema")
+	}
+	enums := map[string]bool{}
+	for _, e := range s.Enums {
+		enums[e.Name] = true
+	}
+	for i, c := range s.Columns {
+		if c.Name == "" {
+			return nil, errors.Errorf("column %d has no name", i)
+		}
+		if c.Type == "" {
+			s.Columns[i].Type = StringType
+		}
+		if c.Enum != "" && !enums[c.Enum] {
+			return nil, errors.Errorf("column %s uses enum %q which isn't defined", c.Name, c.Enum)
+		}
+	}
+	return s, nil
+}
+
+// Index returns the index of the named column, or -1 if there's no such
+// column.
+func (s *Schema) Index(name string) int {
+	s.init()
+	if i, ok := s.index[name]; ok {
+		return i
+	}
+	return -1
+}
+
+// init indexes the columns and resolves their enums the first time the schema
+// is used.
+func (s *Schema) init() {
+	s.once.Do(func() {
+		s.index = make(map[string]int, len(s.Columns))
+		for i := len(s.Columns) - 1; i >= 0; i-- {
+			// Bill headings aren't always unique, the first column wins
+			s.index[s.Columns[i].Name] = i
+		}
+		for i := range s.Columns {
+			if c := &s.Columns[i]; c.Type == DateType || c.Type == DateTimeType {
+				codec, err := c.newDates()
+				c.codec, c.codecErr = &codec, err
+			}
+		}
+		s.resolveEnums()
+	})
+}
+
+// CheckHeading checks that a heading names the schema's columns in order.
+func (s *Schema) CheckHeading(heading []string) error {
+	if len(heading) != len(s.Columns) {
+		return errors.Errorf("heading has %d columns but the schema has %d", len(heading), len(s.Columns))
+	}
+	for i, name := range heading {
+		if name != s.Columns[i].Name {
+			return errors.Errorf("heading %d is %q but the schema expects %q", i, name, s.Columns[i].Name)
+		}
+	}
+	return nil
+}
+
+// ValidationError is returned when a value doesn't satisfy its column.
+type ValidationError struct {
+	Record int
+	Column string
+	Value  string
+	Err    error
+}
+
+func (e *ValidationError) Error() string {
+	return "on record " + strconv.Itoa(e.Record) + ", column " + e.Column + ": " + e.Err.Error()
+}
+
+// validate checks every value of a record, or only those selected.
+func (s *Schema) validate(record int, row [][]byte, selected []bool) error {
+	s.init()
+	for i := range s.Columns {
+		if i >= len(row) {
+			break
+		}
+		if selected != nil && !selected[i] {
+			continue
+		}
+		if err := s.Columns[i].validate(row[i]); err != nil {
+			return &ValidationError{
+				Record: record,
+				Column: s.Columns[i].Name,
+				Value:  string(row[i]),
+				Err:    err,
+			}
+		}
+	}
+	return nil
+}
+
+// Row pairs a record with its schema for typed access to values by column
+// name. Like the record, a Row is only valid within ReadAll's function.
+type Row struct {
+	Schema *Schema
+	Fields [][]byte
+}
+
+// Row returns the record as a Row.
+func (s *Schema) Row(fields [][]byte) Row {
+	return Row{Schema: s, Fields: fields}
+}
+
+// value returns the raw value and column of the named column.
+func (r Row) value(name string) ([]byte, *Column, error) {
+	i := r.Schema.Index(name)
+	if i == -1 || i >= len(r.Fields) {
+		return nil, nil, errors.Errorf("no column named %q", name)
+	}
+	return r.Fields[i], &r.Schema.Columns[i], nil
+}
+
+// IsNull reports whether the named column is empty.
+func (r Row) IsNull(name string) bool {
+	value, _, err := r.value(name)
+	return err == nil && len(value) == 0
+}
+
+// Bytes returns the raw value of the named column, it's only valid within
+// ReadAll's function.
+func (r Row) Bytes(name string) ([]byte, error) {
+	value, _, err := r.value(name)
+	return value, err
+}
+
+// String returns the value of the named column as a string.
+func (r Row) String(name string) (string, error) {
+	value, _, err := r.value(name)
+	return string(value), err
+}
+
+// Int returns the value of the named column as an integer, zero if it's empty.
+func (r Row) Int(name string) (int64, error) {
+	value, _, err := r.value(name)
+	if err != nil || len(value) == 0 {
+		return 0, err
+	}
+	return strconv.ParseInt(string(value), 10, 64)
+}
+
+// Float returns the value of the named column as a float, zero if it's empty.
+// Amounts lose precision as floats, Decimal returns them exactly.
+func (r Row) Float(name string) (float64, error) {
+	value, column, err := r.value(name)
+	if err != nil || len(value) == 0 {
+		return 0, err
+	}
+	if column.Type == DecimalType {
+		d, err := column.decimal(value)
+		return d.Float64(), err
+	}
+	return strconv.ParseFloat(string(value), 64)
+}
+
+// Decimal returns the value of the named column as a Decimal, zero if it's
+// empty. Values without a decimal point have the column's implied decimal
+// places.
+func (r Row) Decimal(name string) (Decimal, error) {
+	value, column, err := r.value(name)
+	if err != nil || len(value) == 0 {
+		return Decimal{}, err
+	}
+	return column.decimal(value)
+}
+
+// Bool returns the value of the named column as a bool, false if it's empty.
+func (r Row) Bool(name string) (bool, error) {
+	value, column, err := r.value(name)
+	if err != nil || len(value) == 0 {
+		return false, err
+	}
+	return column.bool(value)
+}
+
+// Enum returns the value of the named enum column, reading an alias as its
+// code, the zero EnumValue if it's empty.
+func (r Row) Enum(name string) (EnumValue, error) {
+	value, column, err := r.value(name)
+	if err != nil || len(value) == 0 {
+		return EnumValue{}, err
+	}
+	return column.enum(value)
+}
+
+// Time returns the value of the named date or datetime column using the
+// column's layout, the zero time if it's empty or a sentinel.
+func (r Row) Time(name string) (time.Time, error) {
+	t, err := r.NullTime(name)
+	return t.Time, err
+}
+
+// NullTime returns the value of the named date or datetime column using the
+// column's layout, it isn't valid if the value is empty or a sentinel.
+func (r Row) NullTime(name string) (sql.NullTime, error) {
+	value, column, err := r.value(name)
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+	dates, err := column.dates()
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+	return dates.Decode(value)
+}
+
+// Period returns the value of the named period column, the zero Period if
+// it's empty.
+func (r Row) Period(name string) (Period, error) {
+	value, _, err := r.value(name)
+	if err != nil || len(value) == 0 {
+		return Period{}, err
+	}
+	return ParsePeriod(value)
+}
+
+// bool parses a value of a bool column.
+func (c *Column) bool(value []byte) (bool, error) {
+	if c.TrueValues == nil && c.FalseValues == nil {
+		return parseBool(value)
+	}
+	for _, v := range c.TrueValues {
+		if strings.EqualFold(v, string(value)) {
+			return true, nil
+		}
+	}
+	for _, v := range c.FalseValues {
+		if strings.EqualFold(v, string(value)) {
+			return false, nil
+		}
+	}
+	values := append(append([]string{}, c.TrueValues...), c.FalseValues...)
+	return false, errors.Errorf("value isn't one of %s", strings.Join(values, ", "))
+}
+
+// appendBool appends a value of a bool column.
+func (c *Column) appendBool(dst []byte, b bool) []byte {
+	switch {
+	case b && len(c.TrueValues) > 0:
+		return append(dst, c.TrueValues[0]...)
+	case !b && len(c.FalseValues) > 0:
+		return append(dst, c.FalseValues[0]...)
+	case b:
+		return append(dst, "Yes"...)
+	}
+	return append(dst, "No"...)
+}
+
+// parseBool parses the flags Bill writes, such as "Yes", "N" and "1".
+func parseBool(value []byte) (bool, error) {
+	switch strings.ToLower(string(value)) {
+	case "1", "y", "yes", "t", "true":
+		return true, nil
+	case "0", "n", "no", "f", "false":
+		return false, nil
+	}
+	return false, errors.Errorf("%q isn't a boolean", value)
+}
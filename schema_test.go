@@ -0,0 +1,107 @@
+package billdsv
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+const carBonuses = `CrNumber|CrPeriod|CrCarBonusID|CrCBExecID|CrCBRepayment|CrCBRepaymentFee|CrCBBonusEligibl|CrCBBonusAmount|CrCBCommitted|CrCBNotes|CrCBScheme|CrCBSpareC2|CrCBBalance|CrCBSpareNum1|CrCBSpareNum2|CrCBSpareDate1|CrCBSpareDate2
+328|2015/09|1097684|006308|Yes|-150|Yes|0|2015-09-11||Scheme3||4750|0|0|1899-12-30|1899-12-30
+333|2015/11|1155246|006308|Yes|-150|No|0|2015-11-12||Scheme3||13795|0|0|1899-12-30|1899-12-30
+`
+
+func carBonusSchema() *Schema {
+	return &Schema{Columns: []Column{
+		{Name: "CrNumber", Type: IntType},
+		{Name: "CrPeriod", Type: StringType, MaxLength: 7},
+		{Name: "CrCarBonusID", Type: IntType},
+		{Name: "CrCBExecID", Type: StringType},
+		{Name: "CrCBRepayment", Type: BoolType},
+		{Name: "CrCBRepaymentFee", Type: DecimalType},
+		{Name: "CrCBBonusEligibl", Type: EnumType, Values: []string{"Yes", "No"}},
+		{Name: "CrCBBonusAmount", Type: DecimalType},
+		{Name: "CrCBCommitted", Type: DateType},
+		{Name: "CrCBNotes", Type: StringType, Nullable: true, MultiLine: true},
+		{Name: "CrCBScheme", Type: StringType},
+		{Name: "CrCBSpareC2", Type: StringType, Nullable: true},
+		{Name: "CrCBBalance", Type: DecimalType},
+		{Name: "CrCBSpareNum1", Type: IntType},
+		{Name: "CrCBSpareNum2", Type: IntType},
+		{Name: "CrCBSpareDate1", Type: DateType},
+		{Name: "CrCBSpareDate2", Type: DateType},
+	}}
+}
+
+func TestSchemaReader(t *testing.T) {
+	s := carBonusSchema()
+	cr := NewSchemaReader(strings.NewReader(carBonuses), s, DefaultBufferSize)
+	cr.SkipHeading = true
+
+	type result struct {
+		number    int64
+		eligible  bool
+		balance   float64
+		committed time.Time
+		notesNull bool
+	}
+	got := []result{}
+
+	err := cr.ReadAll(func(fields [][]byte) {
+		row := s.Row(fields)
+		number, err := row.Int("CrNumber")
+		assert.Equal(t, nil, err)
+		eligible, err := row.Bool("CrCBRepayment")
+		assert.Equal(t, nil, err)
+		balance, err := row.Float("CrCBBalance")
+		assert.Equal(t, nil, err)
+		committed, err := row.Time("CrCBCommitted")
+		assert.Equal(t, nil, err)
+
+		got = append(got, result{number, eligible, balance, committed, row.IsNull("CrCBNotes")})
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, []result{
+		{328, true, 4750, time.Date(2015, 9, 11, 0, 0, 0, 0, time.UTC), true},
+		{333, true, 13795, time.Date(2015, 11, 12, 0, 0, 0, 0, time.UTC), true},
+	}, got)
+	assert.Equal(t, 17, len(cr.Heading()))
+}
+
+func TestSchemaReaderHeadingMismatch(t *testing.T) {
+	s := carBonusSchema()
+	s.Columns[1].Name = "CrBillingPeriod"
+
+	cr := NewSchemaReader(strings.NewReader(carBonuses), s, DefaultBufferSize)
+	cr.SkipHeading = true
+
+	err := cr.ReadAll(func(fields [][]byte) {})
+	assert.Equal(t, `heading 1 is "CrPeriod" but the schema expects "CrBillingPeriod"`, err.Error())
+}
+
+func TestSchemaReaderInvalidValue(t *testing.T) {
+	s := carBonusSchema()
+	s.Columns[6].Values = []string{"Yes"}
+
+	cr := NewSchemaReader(strings.NewReader(carBonuses), s, DefaultBufferSize)
+	cr.SkipHeading = true
+
+	rows := 0
+	err := cr.ReadAll(func(fields [][]byte) {
+		rows++
+	})
+
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	assert.Equal(t, 1, rows)
+	assert.Equal(t, 2, verr.Record)
+	assert.Equal(t, "CrCBBonusEligibl", verr.Column)
+	assert.Equal(t, "No", verr.Value)
+}
@@ -0,0 +1,54 @@
+package billdsv
+
+import (
+	"context"
+	"io"
+)
+
+// Stream reads all records on a goroutine and sends them on the returned
+// channel, which has a capacity of StreamDepth. Unlike the records passed to
+// ReadAll's function each is a copy the receiver owns.
+//
+// The records channel is closed once reading stops, after which the error
+// channel yields the error reading stopped with, if any, and is closed too.
+// Cancelling ctx stops reading from the underlying reader before its next
+// read and yields the context's error.
+func (r *Reader) Stream(ctx context.Context) (<-chan Record, <-chan error) {
+	records := make(chan Record, r.StreamDepth)
+	errs := make(chan error, 1)
+
+	r.r = &contextReader{ctx: ctx, r: r.r}
+
+	go func() {
+		defer close(errs)
+
+		err := r.readAll(func(row [][]byte) error {
+			select {
+			case records <- CopyRow(row):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(records)
+
+		if err != nil {
+			errs <- err
+		}
+	}()
+
+	return records, errs
+}
+
+// contextReader stops reading once its context is cancelled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
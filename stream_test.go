@@ -0,0 +1,75 @@
+package billdsv
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestReaderStream(t *testing.T) {
+	cr := NewReader(strings.NewReader(batchDocument), 3, DefaultBufferSize)
+	cr.StreamDepth = 2
+
+	records, errs := cr.Stream(context.Background())
+
+	got := []Record{}
+	for record := range records {
+		got = append(got, record)
+	}
+	assert.Equal(t, nil, <-errs)
+
+	// the records are copies, so they outlive the reader's buffers
+	assert.Equal(t, 5, len(got))
+	assert.Equal(t, Record{[]byte("1"), []byte("Amy"), []byte("first")}, got[0])
+	assert.Equal(t, Record{[]byte("2"), []byte("Bob\nline"), []byte("second")}, got[1])
+	assert.Equal(t, Record{[]byte("5"), []byte("Eve"), []byte("fifth")}, got[4])
+}
+
+func TestReaderStreamError(t *testing.T) {
+	cr := NewReader(strings.NewReader("1|a\n2|b|c\n"), 2, DefaultBufferSize)
+
+	records, errs := cr.Stream(context.Background())
+
+	n := 0
+	for range records {
+		n++
+	}
+	assert.Equal(t, 1, n)
+	assert.Equal(t, "on row 1, expected 2 fields but read an extra field", (<-errs).Error())
+
+	_, ok := <-errs
+	assert.Equal(t, false, ok)
+}
+
+// countingReader counts the reads made of it.
+type countingReader struct {
+	r     io.Reader
+	reads int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	c.reads++
+	return c.r.Read(p)
+}
+
+func TestReaderStreamCancel(t *testing.T) {
+	src := &countingReader{r: strings.NewReader(numbered(100000))}
+	cr := NewReader(src, 2, DefaultBufferSize)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	records, errs := cr.Stream(ctx)
+
+	<-records
+	cancel()
+	for range records {
+	}
+	assert.Equal(t, context.Canceled, <-errs)
+
+	// reading stopped long before the end of the document
+	if src.reads > 10 {
+		t.Errorf("expected reading to stop once cancelled, read %d times", src.reads)
+	}
+}
@@ -0,0 +1,67 @@
+package billdsv
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Transform normalises a value, appending the normalised value to dst. It
+// returns an error if the value isn't valid, in which case the value is left
+// as it is and flagged in the reader's Report. Transforms are only given
+// non-empty values.
+type Transform func(dst, value []byte) ([]byte, error)
+
+var (
+	transformsMu sync.RWMutex
+	transforms   = map[string]Transform{}
+)
+
+// RegisterTransform makes a transform available to columns by name, see
+// Column.Transform. Packages of transforms, such as the uk package, register
+// theirs when they're imported. It panics if a transform is registered twice
+// under the same name.
+func RegisterTransform(name string, t Transform) {
+	transformsMu.Lock()
+	defer transformsMu.Unlock()
+
+	if t == nil {
+		panic("billdsv: transform " + name + " is nil")
+	}
+	if _, ok := transforms[name]; ok {
+		panic("billdsv: transform " + name + " is already registered")
+	}
+	transforms[name] = t
+}
+
+// lookupTransform returns the transform registered under a name.
+func lookupTransform(name string) (Transform, error) {
+	transformsMu.RLock()
+	defer transformsMu.RUnlock()
+
+	t, ok := transforms[name]
+	if !ok {
+		return nil, errors.Errorf("transform %q isn't registered", name)
+	}
+	return t, nil
+}
+
+// transform normalises the values of every column with a transform, flagging
+// those that aren't valid.
+func (r *Reader) transform() error {
+	for _, t := range r.transforms {
+		if t.column >= len(r.rowBuffer) || len(r.rowBuffer[t.column]) == 0 || r.unselected(t.column) {
+			continue
+		}
+
+		var err error
+		if r.scratch, err = t.transform(r.scratch[:0], r.rowBuffer[t.column]); err != nil {
+			if err := r.violation(t.column, t.report, err); err != nil {
+				return err
+			}
+			continue
+		}
+		r.rowBuffer[t.column] = append(r.rowBuffer[t.column][:0], r.scratch...)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package billdsv
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	RegisterTransform("test.upper", func(dst, value []byte) ([]byte, error) {
+		if bytes.IndexByte(value, '!') != -1 {
+			return dst, errors.New("value is shouting")
+		}
+		return append(dst, bytes.ToUpper(value)...), nil
+	})
+}
+
+func TestTransform(t *testing.T) {
+	s := &Schema{Columns: []Column{
+		{Name: "Scheme", Type: StringType, Transform: "test.upper", Rules: []Rule{{Values: []string{"SCHEME1", "SCHEME3"}}}},
+		{Name: "Notes", Type: StringType, Nullable: true, Transform: "test.upper"},
+	}}
+	cr := NewSchemaReader(strings.NewReader("scheme3|first\nScheme1|\nscheme9!|second!\n"), s, DefaultBufferSize)
+
+	got := []string{}
+	err := cr.ReadAll(func(row [][]byte) {
+		got = append(got, string(bytes.Join(row, []byte("|"))))
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"SCHEME3|FIRST", "SCHEME1|", "scheme9!|second!"}, got)
+
+	report := cr.Report()
+	assert.Equal(t, 3, report.Violations)
+	assert.Equal(t, []RuleReport{
+		{Rule: "transform test.upper", Violations: 1, Samples: []Violation{{Record: 3, Value: "scheme9!", Message: "value is shouting"}}},
+		{Rule: "one of SCHEME1, SCHEME3", Violations: 1, Samples: []Violation{{Record: 3, Value: "scheme9!", Message: "value isn't one of SCHEME1, SCHEME3"}}},
+	}, report.Columns[0].Rules)
+
+	s.Columns[1].Transform = "test.missing"
+	cr = NewSchemaReader(strings.NewReader("scheme3|first\n"), s, DefaultBufferSize)
+	err = cr.ReadAll(func([][]byte) {})
+	assert.Equal(t, `column Notes: transform "test.missing" isn't registered`, err.Error())
+}
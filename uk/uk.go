@@ -0,0 +1,155 @@
+// Package uk validates and normalises the UK values found in Bill's customer
+// extracts: postcodes, phone numbers, email addresses, sort codes and bank
+// account numbers.
+//
+// Each function appends the normalised value to dst, returning an error if the
+// value isn't valid, so they can be used as billdsv transforms. Importing the
+// package registers them as the "uk.postcode", "uk.phone", "uk.email",
+// "uk.sortcode" and "uk.account" transforms.
+package uk
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/pkg/errors"
+	billdsv "github.com/utilitywarehouse/go-dsv-bill-reader"
+)
+
+func init() {
+	billdsv.RegisterTransform("uk.postcode", Postcode)
+	billdsv.RegisterTransform("uk.phone", Phone)
+	billdsv.RegisterTransform("uk.email", Email)
+	billdsv.RegisterTransform("uk.sortcode", SortCode)
+	billdsv.RegisterTransform("uk.account", AccountNumber)
+}
+
+// postcode matches the outward and inward codes of a postcode written in
+// upper case without spaces. The inward code's letters never include C, I, K,
+// M, O or V.
+var postcode = regexp.MustCompile(`^([A-Z]{1,2}[0-9][A-Z0-9]?)([0-9][ABD-HJLNP-UW-Z]{2})$`)
+
+// Postcode canonicalises a postcode as upper case with a single space between
+// the outward and inward codes, so "sw1a1aa" becomes "SW1A 1AA".
+func Postcode(dst, value []byte) ([]byte, error) {
+	compact := make([]byte, 0, len(value))
+	for _, c := range value {
+		switch {
+		case c == ' ' || c == '\t':
+		case c >= 'a' && c <= 'z':
+			compact = append(compact, c-'a'+'A')
+		default:
+			compact = append(compact, c)
+		}
+	}
+
+	if string(compact) == "GIR0AA" {
+		return append(dst, "GIR 0AA"...), nil
+	}
+	m := postcode.FindSubmatch(compact)
+	if m == nil {
+		return dst, errors.Errorf("%q isn't a postcode", value)
+	}
+	dst = append(dst, m[1]...)
+	dst = append(dst, ' ')
+	return append(dst, m[2]...), nil
+}
+
+// Phone normalises a UK phone number to E.164, so "01768 885022",
+// "+44 (0)1768 885022" and "0044 1768 885022" all become "+441768885022".
+// Spaces, dashes, dots and parentheses are ignored.
+func Phone(dst, value []byte) ([]byte, error) {
+	digits := make([]byte, 0, len(value))
+	plus := false
+	for i, c := range value {
+		switch {
+		case c >= '0' && c <= '9':
+			digits = append(digits, c)
+		case c == '+' && len(digits) == 0 && !plus:
+			plus = true
+		case c == ' ' || c == '-' || c == '.' || c == '(' || c == ')':
+		default:
+			return dst, errors.Errorf("%q isn't a phone number, unexpected %q at %d", value, c, i)
+		}
+	}
+
+	// the national number follows the country code, dropping the trunk
+	// prefix often written as "(0)" after it
+	switch {
+	case plus && bytes.HasPrefix(digits, []byte("44")):
+		digits = bytes.TrimPrefix(digits[2:], []byte("0"))
+	case !plus && bytes.HasPrefix(digits, []byte("0044")):
+		digits = bytes.TrimPrefix(digits[4:], []byte("0"))
+	case !plus && bytes.HasPrefix(digits, []byte("0")):
+		digits = digits[1:]
+	default:
+		return dst, errors.Errorf("%q isn't a UK phone number", value)
+	}
+
+	// national numbers are 10 digits, or 9 for a few 01 area codes, and
+	// never start with 0, 4 or 6
+	switch {
+	case len(digits) == 9 && digits[0] == '1':
+	case len(digits) != 10 || bytes.IndexByte([]byte("046"), digits[0]) != -1:
+		return dst, errors.Errorf("%q isn't a UK phone number", value)
+	}
+	dst = append(dst, "+44"...)
+	return append(dst, digits...), nil
+}
+
+// emailLocal and emailDomain match the parts of an email address in lower
+// case either side of the @.
+var (
+	emailLocal  = regexp.MustCompile(`^[a-z0-9!#$%&'*+/=?^_{|}~-]+(\.[a-z0-9!#$%&'*+/=?^_{|}~-]+)*$`)
+	emailDomain = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,}$`)
+)
+
+// Email lower-cases and trims an email address, checking it has a plausible
+// local part and domain.
+func Email(dst, value []byte) ([]byte, error) {
+	email := bytes.ToLower(bytes.TrimSpace(value))
+	at := bytes.LastIndexByte(email, '@')
+	if at == -1 || !emailLocal.Match(email[:at]) || !emailDomain.Match(email[at+1:]) {
+		return dst, errors.Errorf("%q isn't an email address", value)
+	}
+	return append(dst, email...), nil
+}
+
+// SortCode canonicalises a sort code as three pairs of digits separated by
+// dashes, so "123456" and "12 34 56" become "12-34-56".
+func SortCode(dst, value []byte) ([]byte, error) {
+	digits, err := bankDigits(value)
+	if err != nil || len(digits) != 6 {
+		return dst, errors.Errorf("%q isn't a sort code", value)
+	}
+	return append(dst, digits[0], digits[1], '-', digits[2], digits[3], '-', digits[4], digits[5]), nil
+}
+
+// AccountNumber canonicalises a bank account number as 8 digits, padding the
+// 6 and 7 digit numbers some banks issue with leading zeros.
+func AccountNumber(dst, value []byte) ([]byte, error) {
+	digits, err := bankDigits(value)
+	if err != nil || len(digits) < 6 || len(digits) > 8 {
+		return dst, errors.Errorf("%q isn't an account number", value)
+	}
+	for i := len(digits); i < 8; i++ {
+		dst = append(dst, '0')
+	}
+	return append(dst, digits...), nil
+}
+
+// bankDigits returns the digits of a sort code or account number, ignoring
+// spaces and dashes.
+func bankDigits(value []byte) ([]byte, error) {
+	digits := make([]byte, 0, 8)
+	for _, c := range value {
+		switch {
+		case c >= '0' && c <= '9':
+			digits = append(digits, c)
+		case c == ' ' || c == '-':
+		default:
+			return nil, errors.Errorf("unexpected %q", c)
+		}
+	}
+	return digits, nil
+}
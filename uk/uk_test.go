@@ -0,0 +1,107 @@
+package uk
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+	billdsv "github.com/utilitywarehouse/go-dsv-bill-reader"
+)
+
+func check(t *testing.T, transform billdsv.Transform, valid map[string]string, invalid []string) {
+	t.Helper()
+	for value, want := range valid {
+		got, err := transform([]byte("x"), []byte(value))
+		assert.Equal(t, nil, err, value)
+		assert.Equal(t, "x"+want, string(got), value)
+	}
+	for _, value := range invalid {
+		got, err := transform([]byte("x"), []byte(value))
+		assert.NotEqual(t, nil, err, value)
+		assert.Equal(t, "x", string(got), value)
+	}
+}
+
+func TestPostcode(t *testing.T) {
+	check(t, Postcode, map[string]string{
+		"sw1a1aa":   "SW1A 1AA",
+		"SW1A 1AA":  "SW1A 1AA",
+		" m1  1ae ": "M1 1AE",
+		"ca11 9ha":  "CA11 9HA",
+		"gir 0aa":   "GIR 0AA",
+		"EC1A1BB":   "EC1A 1BB",
+	}, []string{"", "SW1A", "SW1A 1CA", "1AA SW1A", "SW1A-1AA", "SW1A 1AAA"})
+}
+
+func TestPhone(t *testing.T) {
+	check(t, Phone, map[string]string{
+		"07510044409":        "+447510044409",
+		"01768 885022":       "+441768885022",
+		"+44 (0)1768 885022": "+441768885022",
+		"+44 20 7946 0018":   "+442079460018",
+		"0044-1768-885022":   "+441768885022",
+		"(016977) 3456":      "+44169773456",
+	}, []string{"", "1768885022", "+1 555 0100", "0751004440", "075100444099", "04510044409", "01768 885022 ext 2"})
+}
+
+func TestEmail(t *testing.T) {
+	check(t, Email, map[string]string{
+		" J.Coleman@Example.CO.UK ": "j.coleman@example.co.uk",
+		"first+bill@example.com":    "first+bill@example.com",
+	}, []string{"", "jcoleman", "@example.com", "j coleman@example.com", "j..coleman@example.com", "jcoleman@example", "jcoleman@-example.com"})
+}
+
+func TestSortCode(t *testing.T) {
+	check(t, SortCode, map[string]string{
+		"123456":   "12-34-56",
+		"12-34-56": "12-34-56",
+		"12 34 56": "12-34-56",
+	}, []string{"", "--", "12345", "1234567", "12/34/56"})
+}
+
+func TestAccountNumber(t *testing.T) {
+	check(t, AccountNumber, map[string]string{
+		"12345678":  "12345678",
+		"1234567":   "01234567",
+		"123456":    "00123456",
+		"1234 5678": "12345678",
+	}, []string{"", "XXXX0000", "12345", "123456789"})
+}
+
+func TestTransforms(t *testing.T) {
+	s := &billdsv.Schema{Columns: []billdsv.Column{
+		{Name: "CustPostCode", Type: billdsv.StringType, Transform: "uk.postcode"},
+		{Name: "CustPhone", Type: billdsv.StringType, Nullable: true, Transform: "uk.phone"},
+		{Name: "CustEmail", Type: billdsv.StringType, Nullable: true, Transform: "uk.email"},
+		{Name: "CustBankSort", Type: billdsv.StringType, Transform: "uk.sortcode"},
+		{Name: "CustBankAccNo", Type: billdsv.StringType, Transform: "uk.account", MaxLength: 8},
+	}}
+	f := strings.NewReader(`CustPostCode|CustPhone|CustEmail|CustBankSort|CustBankAccNo
+ca11 9ha|01768 885022|J.Coleman@Example.com|123456|1234567
+SW1A 1AA||not an email|--|XXXX0000
+`)
+
+	cr := billdsv.NewSchemaReader(f, s, billdsv.DefaultBufferSize)
+	cr.SkipHeading = true
+
+	got := [][]string{}
+	err := cr.ReadAll(func(row [][]byte) {
+		values := make([]string, len(row))
+		for i, v := range row {
+			values[i] = string(v)
+		}
+		got = append(got, values)
+	})
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, [][]string{
+		{"CA11 9HA", "+441768885022", "j.coleman@example.com", "12-34-56", "01234567"},
+		{"SW1A 1AA", "", "not an email", "--", "XXXX0000"},
+	}, got)
+
+	report := cr.Report()
+	assert.Equal(t, 3, report.Violations)
+	assert.Equal(t, billdsv.RuleReport{Rule: "transform uk.email", Violations: 1, Samples: []billdsv.Violation{
+		{Record: 2, Value: "not an email", Message: `"not an email" isn't an email address`},
+	}}, report.Columns[2].Rules[0])
+}
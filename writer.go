@@ -0,0 +1,136 @@
+package billdsv
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Writer implements a DSV writer that produces documents Reader can read back
+// with the same Separator, Delimiter, Terminator, Quote and Escape settings.
+type Writer struct {
+	Separator  byte
+	Delimiter  string
+	Terminator string
+	Quote      byte
+	Escape     byte
+
+	w *bufio.Writer
+}
+
+// NewWriter returns a new Writer that writes pipe separated values to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		Separator: '|',
+		w:         bufio.NewWriter(w),
+	}
+}
+
+// Write writes a single record followed by the terminator. Values containing
+// the separator are escaped or quoted depending on which is enabled, escaping
+// taking precedence and escaping the quote as well when both are. Values
+// containing line breaks are left as they are unless escaping is enabled since
+// Reader relies on the field count to read them back. That doesn't work for
+// the last value, which can only hold a line break when quoted or escaped.
+// Writes are buffered so Flush must be called once all records have been
+// written.
+func (w *Writer) Write(record [][]byte) (err error) {
+	delim, term := w.Delimiter, w.Terminator
+	if delim == "" {
+		delim = string([]byte{w.Separator})
+	}
+	if term == "" {
+		term = "\n"
+	}
+
+	for i, field := range record {
+		if i > 0 {
+			if _, err = w.w.WriteString(delim); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case w.Escape != 0:
+			err = w.writeEscaped(field, delim, term)
+		case w.Quote != 0 && w.needsQuotes(field, delim, term):
+			err = w.writeQuoted(field)
+		case bytes.Contains(field, []byte(delim)):
+			return errors.Errorf("field %d contains the separator and can't be written without quoting or escaping", i)
+		case i == len(record)-1 && bytes.Contains(field, []byte(term)):
+			return errors.Errorf("field %d is the last and contains the terminator, it can't be written without quoting or escaping", i)
+		default:
+			_, err = w.w.Write(field)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = w.w.WriteString(term)
+	return err
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+func (w *Writer) needsQuotes(field []byte, delim, term string) bool {
+	return (len(field) > 0 && field[0] == w.Quote) ||
+		bytes.Contains(field, []byte(delim)) ||
+		bytes.Contains(field, []byte(term)) ||
+		bytes.IndexByte(field, '\r') != -1
+}
+
+func (w *Writer) writeQuoted(field []byte) error {
+	if err := w.w.WriteByte(w.Quote); err != nil {
+		return err
+	}
+	for _, c := range field {
+		if c == w.Quote {
+			if err := w.w.WriteByte(c); err != nil {
+				return err
+			}
+		}
+		if err := w.w.WriteByte(c); err != nil {
+			return err
+		}
+	}
+	return w.w.WriteByte(w.Quote)
+}
+
+func (w *Writer) writeEscaped(field []byte, delim, term string) (err error) {
+	for i := 0; i < len(field); i++ {
+		switch {
+		case field[i] == w.Escape:
+			_, err = w.w.Write([]byte{w.Escape, w.Escape})
+		case w.Quote != 0 && field[i] == w.Quote:
+			_, err = w.w.Write([]byte{w.Escape, w.Quote})
+		case field[i] == '\n':
+			_, err = w.w.Write([]byte{w.Escape, 'n'})
+		case field[i] == '\r':
+			_, err = w.w.Write([]byte{w.Escape, 'r'})
+		case bytes.HasPrefix(field[i:], []byte(delim)):
+			err = w.w.WriteByte(w.Escape)
+			if err == nil {
+				_, err = w.w.WriteString(delim)
+			}
+			i += len(delim) - 1
+		case bytes.HasPrefix(field[i:], []byte(term)):
+			err = w.w.WriteByte(w.Escape)
+			if err == nil {
+				_, err = w.w.WriteString(term)
+			}
+			i += len(term) - 1
+		default:
+			err = w.w.WriteByte(field[i])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package billdsv
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestWriterRoundTrip(t *testing.T) {
+	records := [][]string{
+		{"1000", "first|string", "final string"},
+		{"1001", "second string\nthat is multi-line\\", "final \"quoted\" string"},
+		{"1002", "", "\"third\" string"},
+		{"\"q\" x", "\\\"", "z"},
+	}
+
+	for _, configure := range []func(w *Writer, r *Reader){
+		func(w *Writer, r *Reader) { w.Escape, r.Escape = '\\', '\\' },
+		func(w *Writer, r *Reader) { w.Quote, r.Quote = '"', '"' },
+		func(w *Writer, r *Reader) {
+			w.Quote, r.Quote = '"', '"'
+			w.Escape, r.Escape = '\\', '\\'
+		},
+		func(w *Writer, r *Reader) {
+			w.Delimiter, r.Delimiter = "~|~", "~|~"
+			w.Terminator, r.Terminator = "\x1e", "\x1e"
+			w.Escape, r.Escape = '\\', '\\'
+		},
+	} {
+		buf := &bytes.Buffer{}
+		cw := NewWriter(buf)
+		cr := NewReader(buf, 3, DefaultBufferSize)
+		configure(cw, cr)
+
+		for _, record := range records {
+			row := make([][]byte, len(record))
+			for i, s := range record {
+				row[i] = []byte(s)
+			}
+			if err := cw.Write(row); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := cw.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		got := [][]string{}
+		err := cr.ReadAll(func(row [][]byte) {
+			rowStrings := make([]string, 3)
+			for i, c := range row {
+				rowStrings[i] = string(c)
+			}
+			got = append(got, rowStrings)
+		})
+		if err != nil {
+			t.Error(err)
+		}
+
+		assert.Equal(t, records, got)
+	}
+}
+
+func TestWriterSeparatorInValue(t *testing.T) {
+	cw := NewWriter(&bytes.Buffer{})
+	err := cw.Write([][]byte{[]byte("first|string")})
+	assert.NotEqual(t, nil, err)
+}
+
+func TestWriterTerminatorInLastValue(t *testing.T) {
+	b := &bytes.Buffer{}
+	cw := NewWriter(b)
+
+	// line breaks are read back by the field count, except in the last value
+	assert.Equal(t, nil, cw.Write([][]byte{[]byte("first\nline"), []byte("last")}))
+	err := cw.Write([][]byte{[]byte("first"), []byte("last\nline")})
+	assert.Equal(t, "field 1 is the last and contains the terminator, it can't be written without quoting or escaping", err.Error())
+
+	cw.Quote = '"'
+	assert.Equal(t, nil, cw.Write([][]byte{[]byte("first"), []byte("last\nline")}))
+}